@@ -0,0 +1,76 @@
+// Package bqbtest provides helpers for testing code that builds queries
+// with bqb.
+package bqbtest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nullism/bqb"
+)
+
+var update = flag.Bool("bqbtest.update", false, "update bqbtest golden files")
+
+// AssertQuery reports an error if q does not compile for dialect to
+// wantSql and wantParams.
+func AssertQuery(t testing.TB, q *bqb.Query, dialect bqb.Dialect, wantSql string, wantParams ...any) {
+	t.Helper()
+
+	sql, params, err := q.ToDialect(dialect)
+	if err != nil {
+		t.Errorf("%v query error: %v", dialect, err)
+		return
+	}
+	if sql != wantSql {
+		t.Errorf("%v sql:\n got: %q\nwant: %q", dialect, sql, wantSql)
+	}
+	if len(params) != len(wantParams) || len(params) > 0 && !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("%v params:\n got: %#v\nwant: %#v", dialect, params, wantParams)
+	}
+}
+
+// Golden compares the formatted SQL and params of q with the file
+// testdata/<test name>.golden. Run the tests with -bqbtest.update to write
+// the file instead.
+func Golden(t testing.TB, q *bqb.Query) {
+	t.Helper()
+
+	sql, params, err := q.ToSqlFormatted(bqb.FormatOptions{})
+	if err != nil {
+		t.Errorf("query error: %v", err)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(sql)
+	b.WriteString("\n\n-- params:\n")
+	for i, p := range params {
+		fmt.Fprintf(&b, "-- %d: %#v\n", i+1, p)
+	}
+	got := b.String()
+
+	path := filepath.Join("testdata", strings.ReplaceAll(t.Name(), "/", "_")+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatalf("cannot create testdata: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("cannot write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read golden file (run with -bqbtest.update to create it): %v", err)
+		return
+	}
+	if got != string(want) {
+		t.Errorf("%v does not match:\n got:\n%s\nwant:\n%s", path, got, want)
+	}
+}
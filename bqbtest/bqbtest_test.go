@@ -0,0 +1,95 @@
+package bqbtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nullism/bqb"
+)
+
+type fakeTB struct {
+	testing.TB
+	name   string
+	errors []string
+}
+
+func (f *fakeTB) Helper()      {}
+func (f *fakeTB) Name() string { return f.name }
+
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Fatalf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertQuery(t *testing.T) {
+	q := bqb.New("SELECT * FROM users WHERE id = ? AND name IN (?)", 1, []string{"a", "b"})
+	AssertQuery(t, q, bqb.PGSQL, "SELECT * FROM users WHERE id = $1 AND name IN ($2,$3)", 1, "a", "b")
+	AssertQuery(t, bqb.New("SELECT 1"), bqb.MYSQL, "SELECT 1")
+	AssertQuery(t, q, bqb.RAW, "SELECT * FROM users WHERE id = 1 AND name IN ('a','b')")
+
+	fake := &fakeTB{}
+	AssertQuery(fake, q, bqb.SQL, "SELECT 1", 2)
+	if len(fake.errors) != 2 || !strings.Contains(fake.errors[0], "sql") || !strings.Contains(fake.errors[1], "params") {
+		t.Errorf("unexpected errors: %v", fake.errors)
+	}
+
+	fake = &fakeTB{}
+	AssertQuery(fake, bqb.New("?"), bqb.SQL, "?")
+	if len(fake.errors) != 1 || !strings.Contains(fake.errors[0], "extra ?") {
+		t.Errorf("unexpected errors: %v", fake.errors)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	q := bqb.New("SELECT id, name FROM users WHERE age > ? AND name = ?", 21, "ed")
+	Golden(t, q)
+	if *update {
+		return
+	}
+
+	fake := &fakeTB{name: "TestGolden"}
+	Golden(fake, bqb.New("SELECT 1"))
+	if len(fake.errors) != 1 || !strings.Contains(fake.errors[0], "does not match") {
+		t.Errorf("unexpected errors: %v", fake.errors)
+	}
+
+	fake = &fakeTB{name: "TestGolden/missing"}
+	Golden(fake, q)
+	if len(fake.errors) != 1 || !strings.Contains(fake.errors[0], "-bqbtest.update") {
+		t.Errorf("unexpected errors: %v", fake.errors)
+	}
+
+	fake = &fakeTB{name: "TestGolden"}
+	Golden(fake, bqb.New("?"))
+	if len(fake.errors) != 1 || !strings.Contains(fake.errors[0], "query error") {
+		t.Errorf("unexpected errors: %v", fake.errors)
+	}
+}
+
+func TestGolden_Update(t *testing.T) {
+	wd, _ := os.Getwd()
+	defer func() { _ = os.Chdir(wd) }()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	*update = true
+	defer func() { *update = false }()
+
+	Golden(t, bqb.New("SELECT ?", 1))
+
+	data, err := os.ReadFile(filepath.Join("testdata", "TestGolden_Update.golden"))
+	if err != nil {
+		t.Fatalf("golden file not written: %v", err)
+	}
+	want := "SELECT\n  ?\n\n-- params:\n-- 1: 1\n"
+	if string(data) != want {
+		t.Errorf("\n got: %q\nwant: %q", data, want)
+	}
+}
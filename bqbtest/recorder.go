@@ -0,0 +1,330 @@
+package bqbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nullism/bqb"
+)
+
+var (
+	_ bqb.Execer  = (*Recorder)(nil)
+	_ bqb.Queryer = (*Recorder)(nil)
+
+	commentRe     = regexp.MustCompile(`/\*.*?\*/|--[^\n]*`)
+	placeholderRe = regexp.MustCompile(`\$\d+|@p\d+|:\d+|\?`)
+	spaceRe       = regexp.MustCompile(`\s+`)
+	punctRe       = regexp.MustCompile(`\s*([(),])\s*`)
+	listRe        = regexp.MustCompile(`\?(,\?)+`)
+)
+
+// Call is a query executed through a Recorder, with its args converted to
+// driver values the way database/sql does, e.g. int to int64.
+type Call struct {
+	SQL  string
+	Args []any
+}
+
+// Expectation scripts the response of a Recorder to a matching query.
+type Expectation struct {
+	fingerprint string
+	args        []any
+	matchArgs   bool
+	columns     []string
+	rows        [][]driver.Value
+	result      driver.Result
+	err         error
+	met         bool
+}
+
+// WithArgs only matches queries bound to args.
+func (e *Expectation) WithArgs(args ...any) *Expectation {
+	e.args = convertArgs(args)
+	e.matchArgs = true
+	return e
+}
+
+// WillReturnRows scripts the rows returned by QueryContext.
+func (e *Expectation) WillReturnRows(columns []string, rows ...[]any) *Expectation {
+	e.columns = columns
+	e.rows = make([][]driver.Value, len(rows))
+	for i, row := range rows {
+		values := convertArgs(row)
+		e.rows[i] = make([]driver.Value, len(values))
+		for j, v := range values {
+			e.rows[i][j] = v
+		}
+	}
+	return e
+}
+
+// WillReturnResult scripts the result returned by ExecContext.
+func (e *Expectation) WillReturnResult(lastInsertId, rowsAffected int64) *Expectation {
+	e.result = result{lastInsertId: lastInsertId, rowsAffected: rowsAffected}
+	return e
+}
+
+// WillReturnError scripts the error returned for the query.
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// Recorder is a fake database that records each query it is given and
+// responds with scripted rows, results or errors. Queries are matched to
+// expectations by their Fingerprint, and each expectation is used once.
+type Recorder struct {
+	mu           sync.Mutex
+	db           *sql.DB
+	calls        []Call
+	expectations []*Expectation
+}
+
+// NewRecorder returns a Recorder with no expectations.
+func NewRecorder() *Recorder {
+	r := &Recorder{}
+	r.db = sql.OpenDB(connector{r: r})
+	return r
+}
+
+// DB returns a *sql.DB backed by the Recorder.
+func (r *Recorder) DB() *sql.DB {
+	return r.db
+}
+
+// ExecContext implements bqb.Execer.
+func (r *Recorder) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.db.ExecContext(ctx, query, args...)
+}
+
+// QueryContext implements bqb.Queryer.
+func (r *Recorder) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return r.db.QueryContext(ctx, query, args...)
+}
+
+// Calls returns every query executed through the Recorder.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call{}, r.calls...)
+}
+
+// Expect adds an expectation matching the fingerprint and params of q
+// compiled with ToSql.
+func (r *Recorder) Expect(q *bqb.Query) *Expectation {
+	sql, params, err := q.ToSql()
+	e := r.ExpectSql(sql)
+	if err != nil {
+		e.err = err
+	}
+	return e.WithArgs(params...)
+}
+
+// ExpectSql adds an expectation matching queries with the same
+// fingerprint as sql, bound to any args.
+func (r *Recorder) ExpectSql(sql string) *Expectation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := &Expectation{fingerprint: Fingerprint(sql)}
+	r.expectations = append(r.expectations, e)
+	return e
+}
+
+// Verify reports an error for each expectation that was not used.
+func (r *Recorder) Verify(t testing.TB) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.expectations {
+		if !e.met {
+			t.Errorf("bqbtest: expected query was not run: %v %v", e.fingerprint, e.args)
+		}
+	}
+}
+
+func (r *Recorder) match(query string, named []driver.NamedValue) (*Expectation, error) {
+	args := make([]any, len(named))
+	for i, nv := range named {
+		args[i] = nv.Value
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, Call{SQL: query, Args: args})
+
+	fingerprint := Fingerprint(query)
+	for _, e := range r.expectations {
+		if e.met || e.fingerprint != fingerprint {
+			continue
+		}
+		if e.matchArgs && !(len(e.args) == 0 && len(args) == 0) && !reflect.DeepEqual(e.args, args) {
+			continue
+		}
+		e.met = true
+		return e, e.err
+	}
+	return nil, fmt.Errorf("bqbtest: unexpected query: %v %v", fingerprint, args)
+}
+
+// Fingerprint normalizes sql for matching, removing comments and
+// whitespace differences, lowercasing, and replacing the placeholders of
+// every dialect, including lists of them such as `IN (?,?,?)`, with a
+// single ?.
+func Fingerprint(sql string) string {
+	sql = commentRe.ReplaceAllString(sql, " ")
+	sql = placeholderRe.ReplaceAllString(sql, "?")
+	sql = strings.ToLower(sql)
+	sql = spaceRe.ReplaceAllString(sql, " ")
+	sql = punctRe.ReplaceAllString(sql, "$1")
+	sql = listRe.ReplaceAllString(sql, "?")
+	return strings.TrimSuffix(strings.TrimSpace(sql), ";")
+}
+
+func convertArgs(args []any) []any {
+	converted := make([]any, len(args))
+	for i, a := range args {
+		v, err := driver.DefaultParameterConverter.ConvertValue(a)
+		if err != nil {
+			v = a
+		}
+		converted[i] = v
+	}
+	return converted
+}
+
+type connector struct {
+	r *Recorder
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{r: c.r}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return recorderDriver{}
+}
+
+type recorderDriver struct{}
+
+func (recorderDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("bqbtest: use NewRecorder")
+}
+
+type conn struct {
+	r *Recorder
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return tx{}, nil
+}
+
+func (c *conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.r.match(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.result == nil {
+		return result{}, nil
+	}
+	return e.result, nil
+}
+
+func (c *conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.r.match(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: e.columns, values: e.rows}, nil
+}
+
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, a := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: a}
+	}
+	return named
+}
+
+type tx struct{}
+
+func (tx) Commit() error {
+	return nil
+}
+
+func (tx) Rollback() error {
+	return nil
+}
+
+type result struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	i       int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.i >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.i])
+	r.i++
+	return nil
+}
@@ -0,0 +1,104 @@
+package bqbtest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nullism/bqb"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := map[string]string{
+		"SELECT *\n  FROM users WHERE id = $1":               "select * from users where id = ?",
+		"select * from users where id = @p1 /* c */":         "select * from users where id = ?",
+		"SELECT * FROM users WHERE id IN (:1, :2, :3);":      "select * from users where id in(?)",
+		"SELECT * FROM users WHERE id IN ( ?,? ) -- trailer": "select * from users where id in(?)",
+		"SELECT x::int FROM t":                               "select x::int from t",
+	}
+	for sql, want := range tests {
+		if got := Fingerprint(sql); got != want {
+			t.Errorf("Fingerprint(%q) = %q, want %q", sql, got, want)
+		}
+	}
+}
+
+func TestRecorderQuery(t *testing.T) {
+	ctx := context.Background()
+	rec := NewRecorder()
+	rec.Expect(bqb.New("SELECT id, name FROM users WHERE id IN (?)", []int{1, 2})).
+		WillReturnRows([]string{"id", "name"}, []any{1, "ed"}, []any{2, "al"})
+
+	sql, params, _ := bqb.New("SELECT id, name\nFROM users WHERE id IN (?)", []int{1, 2}).ToPgsql()
+	var db bqb.Queryer = rec
+	rows, err := db.QueryContext(ctx, sql, params...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if !reflect.DeepEqual(names, []string{"ed", "al"}) {
+		t.Errorf("unexpected names: %v", names)
+	}
+
+	calls := rec.Calls()
+	if len(calls) != 1 || calls[0].SQL != sql || !reflect.DeepEqual(calls[0].Args, []any{int64(1), int64(2)}) {
+		t.Errorf("unexpected calls: %#v", calls)
+	}
+	rec.Verify(t)
+}
+
+func TestRecorderExec(t *testing.T) {
+	ctx := context.Background()
+	rec := NewRecorder()
+	rec.ExpectSql("UPDATE users SET name = ?").WillReturnResult(0, 3)
+	boom := errors.New("boom")
+	rec.ExpectSql("DELETE FROM users WHERE id = ?").WithArgs(7).WillReturnError(boom)
+
+	var db bqb.Execer = rec
+	res, err := db.ExecContext(ctx, "UPDATE users SET name = $1", "ed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("unexpected rows affected: %v", n)
+	}
+
+	if _, err := db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", 8); err == nil || !strings.Contains(err.Error(), "unexpected query") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", 7); !errors.Is(err, boom) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// each expectation is used once
+	if _, err := db.ExecContext(ctx, "UPDATE users SET name = ?", "al"); err == nil {
+		t.Error("expected error")
+	}
+	if len(rec.Calls()) != 4 {
+		t.Errorf("unexpected calls: %v", rec.Calls())
+	}
+	rec.Verify(t)
+}
+
+func TestRecorderVerify(t *testing.T) {
+	rec := NewRecorder()
+	rec.Expect(bqb.New("SELECT 1"))
+
+	fake := &fakeTB{}
+	rec.Verify(fake)
+	if len(fake.errors) != 1 || !strings.Contains(fake.errors[0], "select 1") {
+		t.Errorf("unexpected errors: %v", fake.errors)
+	}
+}
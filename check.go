@@ -0,0 +1,184 @@
+package bqb
+
+import (
+	"fmt"
+)
+
+// SyntaxError is returned by Check for compiled SQL that does not parse.
+type SyntaxError struct {
+	Dialect Dialect
+	SQL     string
+	Near    string
+	Msg     string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v syntax error near %q: %v", e.Dialect, e.Near, e.Msg)
+}
+
+// Check compiles the Query for dialect and parses the result with the SQL
+// grammar of dialect, returning a *SyntaxError for problems such as `IN ()`,
+// a dangling AND, a trailing comma, unbalanced parentheses or a clause the
+// dialect doesn't support, e.g. LIMIT for MSSQL. It is intended for unit
+// tests of query builders, since bqb otherwise cannot notice broken output.
+// Note: The grammar covers the SELECT, INSERT, UPDATE, DELETE and MERGE
+// statements with common table expressions, separated by semicolons. Names
+// and types are not checked against a schema.
+func (q *Query) Check(dialect Dialect) error {
+	sql, _, err := q.compile(dialect)
+	if err != nil {
+		return err
+	}
+	return parseSql(dialect, sql)
+}
+
+// sqlGrammar holds the differences between the dialects parsed by Check.
+type sqlGrammar struct {
+	// param is the first character of a placeholder: ?, $, @ or :.
+	param byte
+	// Lexing
+	backtick          bool
+	bracket           bool
+	doubleQuoteString bool
+	backslashEscape   bool
+	hashComment       bool
+	hashIdent         bool
+	dollarQuote       bool
+	// Clauses
+	limit       bool
+	limitComma  bool
+	offsetFetch bool
+	// orderedFetch requires ORDER BY before OFFSET and FETCH.
+	orderedFetch bool
+	top          bool
+	output       bool
+	returning    bool
+	onConflict   bool
+	onDuplicate  bool
+	merge        bool
+	apply        bool
+	lateral      bool
+	// mysqlDml allows the MySQL forms of INSERT, UPDATE and DELETE, such
+	// as INSERT IGNORE, INSERT ... SET and DELETE ... LIMIT.
+	mysqlDml bool
+	// tableAs allows AS before a table alias.
+	tableAs bool
+	// Expressions
+	castOp     bool
+	subscripts bool
+	ilike      bool
+	regexp     bool
+	setOps     map[string]bool
+	binaryOps  map[string]bool
+	reserved   map[string]bool
+}
+
+var (
+	commonBinaryOps = []string{"+", "-", "*", "/", "%", "&", "|", "^"}
+	pgBinaryOps     = []string{"||", "<<", ">>", "->", "->>", "#>", "#>>", "@>", "<@", "?", "?|", "?&", "~", "~*", "!~", "!~*", "&&", "#"}
+	mysqlBinaryOps  = []string{"||", "<<", ">>", "->", "->>", "&&"}
+	setOps          = []string{"UNION", "INTERSECT", "EXCEPT"}
+)
+
+var (
+	pgGrammar = &sqlGrammar{
+		param: '$', dollarQuote: true,
+		limit: true, offsetFetch: true, returning: true, onConflict: true, merge: true, lateral: true,
+		tableAs: true, castOp: true, subscripts: true, ilike: true,
+		setOps:    wordSet(setOps),
+		binaryOps: wordSet(commonBinaryOps, pgBinaryOps),
+		reserved:  wordSet(reservedWords),
+	}
+	mysqlGrammar = &sqlGrammar{
+		param: '?', backtick: true, doubleQuoteString: true, backslashEscape: true, hashComment: true,
+		limit: true, limitComma: true, onDuplicate: true, lateral: true, mysqlDml: true, tableAs: true,
+		regexp:    true,
+		setOps:    wordSet(setOps),
+		binaryOps: wordSet(commonBinaryOps, mysqlBinaryOps),
+		reserved:  wordSet(reservedWords, mysqlReservedWords),
+	}
+	mariadbGrammar = func() *sqlGrammar {
+		g := *mysqlGrammar
+		g.returning = true
+		return &g
+	}()
+	mssqlGrammar = &sqlGrammar{
+		param: '@', bracket: true, hashIdent: true,
+		offsetFetch: true, orderedFetch: true, top: true, output: true, merge: true, apply: true,
+		tableAs:   true,
+		setOps:    wordSet(setOps),
+		binaryOps: wordSet(commonBinaryOps),
+		reserved:  wordSet(reservedWords, mssqlReservedWords),
+	}
+	oracleGrammar = &sqlGrammar{
+		param: ':', offsetFetch: true, merge: true, lateral: true,
+		setOps:    wordSet(setOps, []string{"MINUS"}),
+		binaryOps: wordSet([]string{"+", "-", "*", "/", "||"}),
+		reserved:  wordSet(reservedWords, []string{"MINUS"}),
+	}
+	// genericGrammar accepts the clauses of every dialect that uses ?
+	// placeholders, since the database is unknown.
+	genericGrammar = &sqlGrammar{
+		param: '?', backtick: true,
+		limit: true, limitComma: true, offsetFetch: true, returning: true, onConflict: true, onDuplicate: true,
+		merge: true, lateral: true, mysqlDml: true, tableAs: true, castOp: true, ilike: true, regexp: true,
+		setOps:    wordSet(setOps),
+		binaryOps: wordSet(commonBinaryOps, mysqlBinaryOps),
+		reserved:  wordSet(reservedWords),
+	}
+	rawGrammar = func() *sqlGrammar {
+		g := *genericGrammar
+		g.param = 0
+		return &g
+	}()
+)
+
+// reservedWords cannot be used as names or aliases without quoting.
+var reservedWords = []string{
+	"ALL", "AND", "ANY", "AS", "ASC", "BETWEEN", "BY", "CASE", "COLLATE", "CROSS",
+	"DEFAULT", "DELETE", "DESC", "DISTINCT", "DO", "ELSE", "END", "ESCAPE",
+	"EXCEPT", "EXISTS", "FALSE", "FETCH", "FOR", "FROM", "FULL", "GROUP",
+	"HAVING", "ILIKE", "IN", "INNER", "INSERT", "INTERSECT", "INTO", "IS",
+	"JOIN", "LATERAL", "LEFT", "LIKE", "LIMIT", "MERGE", "NATURAL", "NOT",
+	"NULL", "OFFSET", "ON", "OR", "ORDER", "OUTER", "RETURNING", "RIGHT",
+	"SELECT", "SET", "SOME", "THEN", "TRUE", "UNION", "UPDATE", "USING",
+	"VALUES", "WHEN", "WHERE", "WINDOW", "WITH",
+}
+
+var (
+	mysqlReservedWords = []string{"DIV", "MOD", "REGEXP", "RLIKE", "STRAIGHT_JOIN", "XOR"}
+	mssqlReservedWords = []string{"APPLY", "OUTPUT", "TOP"}
+)
+
+// functionWords are reserved words which are also functions when followed
+// by `(`, e.g. LEFT(name, 1) or VALUES(col) in ON DUPLICATE KEY UPDATE.
+var functionWords = wordSet([]string{"LEFT", "RIGHT", "VALUES", "INSERT", "MOD"})
+
+func wordSet(lists ...[]string) map[string]bool {
+	set := map[string]bool{}
+	for _, list := range lists {
+		for _, w := range list {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+func grammarFor(dialect Dialect) *sqlGrammar {
+	switch dialect {
+	case PGSQL:
+		return pgGrammar
+	case MYSQL:
+		return mysqlGrammar
+	case MARIADB:
+		return mariadbGrammar
+	case MSSQL:
+		return mssqlGrammar
+	case ORACLE:
+		return oracleGrammar
+	case RAW:
+		return rawGrammar
+	default:
+		return genericGrammar
+	}
+}
@@ -0,0 +1,99 @@
+package bqb
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQuery_Check(t *testing.T) {
+	valid := map[Dialect][]*Query{
+		PGSQL: {
+			New("SELECT * FROM users WHERE id IN (?) AND name IS NOT NULL", []int{1, 2}),
+			New("SELECT a, COUNT(*) FROM t WHERE x BETWEEN ? AND ? OR NOT (y = 'it''s, AND (')", 1, 2).Space("GROUP BY a"),
+			Upsert("t", []string{"a"}, []string{"a"}, []string{"a"}, 1).Returning("a"),
+			New("SELECT 1 FROM t WHERE NOT EXISTS (SELECT 1)"),
+			New("SELECT id, LEFT(name, 1), RIGHT(name, 1) FROM users"),
+			New("SELECT data->>'k', tags::text[], CAST(a AS DOUBLE PRECISION) FROM t AS x WHERE data ?? 'k'"),
+			New("WITH c AS (SELECT 1) SELECT * FROM c ORDER BY 1 DESC NULLS LAST LIMIT ? OFFSET ?", 10, 20),
+			New("SELECT ROW_NUMBER() OVER (PARTITION BY a ORDER BY b) FROM t LEFT JOIN u ON t.id = u.id"),
+			New("UPDATE t SET a = ?, b = DEFAULT FROM u WHERE t.id = u.id RETURNING *", 1),
+			New("DELETE FROM t USING u WHERE t.id = u.id; SELECT 1"),
+		},
+		MYSQL: {
+			New("SELECT `a` FROM t -- trailing AND\nWHERE b = ? /* , */", 1),
+			New("INSERT INTO t (a, b) SELECT a, VALUES(b) FROM u"),
+			New("INSERT IGNORE INTO t (a) VALUES (?) ON DUPLICATE KEY UPDATE a = VALUES(a)", 1),
+			New("SELECT a FROM t WHERE b REGEXP '^x' LIMIT 10, 20"),
+			New("DELETE FROM t WHERE a = 1 ORDER BY b LIMIT 1"),
+		},
+		MSSQL: {
+			New("SELECT TOP 10 [a] FROM t WITH (NOLOCK) WHERE b = ?", 1),
+			New("SELECT a FROM t ORDER BY a OFFSET ? ROWS FETCH NEXT ? ROWS ONLY", 10, 20),
+			New("INSERT INTO t (a) OUTPUT INSERTED.id VALUES (?)", 1),
+			New("SELECT a FROM t CROSS APPLY f(t.id)"),
+		},
+		ORACLE: {
+			New("SELECT a FROM t x WHERE b = ? MINUS SELECT a FROM u", 1),
+			New("SELECT a FROM t ORDER BY a FETCH FIRST 10 ROWS ONLY"),
+		},
+	}
+	for dialect, queries := range valid {
+		for _, q := range queries {
+			if err := q.Check(dialect); err != nil {
+				t.Errorf("got error: %v", err)
+			}
+		}
+	}
+
+	invalid := map[string]*Query{
+		"empty IN list":                 New("SELECT * FROM users WHERE id IN (?)", []string{}),
+		"missing condition after AND":   New("SELECT * FROM users WHERE a = 1 AND").Space("ORDER BY a"),
+		"missing condition before OR":   New("SELECT * FROM users WHERE (OR a = 1)"),
+		"missing condition after WHERE": New("SELECT * FROM users WHERE"),
+		"trailing comma":                New("SELECT a, FROM users"),
+		"missing condition after ON":    New("SELECT a FROM b JOIN c ON LEFT JOIN d"),
+		"leading comma":                 New("SELECT , a FROM users"),
+		"extra comma":                   New("SELECT a,, b FROM users"),
+		"unbalanced parentheses":        New("SELECT (a FROM users"),
+		"unterminated string":           New("SELECT 'a FROM users"),
+		"unterminated comment":          New("SELECT a /* b FROM t"),
+		"unexpected )":                  New("SELECT a) FROM t"),
+		"missing operand after =":       New("SELECT a FROM t WHERE b = ORDER BY a"),
+		"expected a table, found WHERE": New("SELECT a FROM WHERE b = 1"),
+	}
+	for msg, q := range invalid {
+		err := q.Check(MYSQL)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q error, got: %v", msg, err)
+		}
+	}
+
+	unsupported := map[Dialect]*Query{
+		MSSQL:  New("SELECT a FROM t LIMIT 10"),
+		ORACLE: New("SELECT a FROM t AS x"),
+		MYSQL:  New("INSERT INTO t (a) VALUES (1) ON CONFLICT (a) DO NOTHING"),
+		PGSQL:  New("SELECT a FROM t WHERE b REGEXP 'x'"),
+	}
+	for dialect, q := range unsupported {
+		if err := q.Check(dialect); err == nil {
+			t.Errorf("expected %v error for %v", dialect, q.Parts[0].Text)
+		}
+	}
+
+	err := New("SELECT a FROM t OFFSET 10 ROWS").Check(MSSQL)
+	if err == nil || !strings.Contains(err.Error(), "OFFSET requires ORDER BY") {
+		t.Errorf("expected OFFSET error, got: %v", err)
+	}
+
+	if err := New("?").Check(SQL); err == nil || !strings.Contains(err.Error(), "extra ?") {
+		t.Errorf("expected compile error, got: %v", err)
+	}
+
+	err = New("SELECT * FROM users WHERE a = ? AND", 1).Check(PGSQL)
+	want := `postgres syntax error near "a = $1 AND": missing condition after AND`
+	if err == nil || err.Error() != want {
+		t.Errorf("\n got: %v\nwant: %v", err, want)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nullism/bqb"
+)
+
+var qualifierRe = regexp.MustCompile(`([A-Za-z_]\w*)\.`)
+
+type param struct {
+	name string
+	typ  string
+}
+
+// generate returns the formatted Go source of a function for each template.
+func generate(pkg string, templates map[string]*bqb.Template) ([]byte, error) {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	imports := map[string]bool{"github.com/nullism/bqb": true}
+	var funcs bytes.Buffer
+	for _, name := range names {
+		t := templates[name]
+		params, err := parseParams(t)
+		if err != nil {
+			return nil, fmt.Errorf("template %v: %w", name, err)
+		}
+		args, err := bindArgs(t, params)
+		if err != nil {
+			return nil, fmt.Errorf("template %v: %w", name, err)
+		}
+		addImports(imports, t, params)
+
+		decls := make([]string, len(params))
+		for i, p := range params {
+			decls[i] = p.name + " " + p.typ
+		}
+
+		funcName := exportedName(name)
+		doc := funcName + " returns the " + name + " query."
+		if desc := t.Annotations["description"]; len(desc) > 0 {
+			doc = funcName + " returns " + strings.Join(desc, " ")
+		}
+		fmt.Fprintf(&funcs, "\n// %s\nfunc %s(%s) *bqb.Query {\n\treturn bqb.New(%s)\n}\n",
+			doc, funcName, strings.Join(decls, ", "),
+			strings.Join(append([]string{strconv.Quote(t.SQL)}, args...), ", "))
+	}
+
+	// Standard library imports are grouped before the others.
+	var std, other []string
+	for p := range imports {
+		if strings.Contains(strings.Split(p, "/")[0], ".") {
+			other = append(other, strconv.Quote(p))
+		} else {
+			std = append(std, strconv.Quote(p))
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by bqbgen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	for _, group := range [][]string{std, other} {
+		if len(group) > 0 {
+			fmt.Fprintf(&src, "\t%s\n\n", strings.Join(group, "\n\t"))
+		}
+	}
+	src.WriteString(")\n")
+	src.Write(funcs.Bytes())
+
+	return format.Source(src.Bytes())
+}
+
+// parseParams returns the `-- param: name type` annotations of t.
+func parseParams(t *bqb.Template) ([]param, error) {
+	var params []param
+	seen := map[string]bool{}
+	for _, a := range t.Annotations["param"] {
+		fields := strings.Fields(a)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid param annotation: %q", a)
+		}
+		p := param{name: fields[0], typ: strings.Join(fields[1:], " ")}
+		if seen[p.name] {
+			return nil, fmt.Errorf("duplicate param: %v", p.name)
+		}
+		seen[p.name] = true
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+// bindArgs returns the arguments passed to bqb.New for t, checking that
+// the params match the placeholders of the template.
+func bindArgs(t *bqb.Template, params []param) ([]string, error) {
+	if t.Params == nil {
+		count := countPlaceholders(t.SQL)
+		if count != len(params) {
+			return nil, fmt.Errorf("%d ? placeholders but %d params", count, len(params))
+		}
+		args := make([]string, len(params))
+		for i, p := range params {
+			args[i] = p.name
+		}
+		return args, nil
+	}
+
+	declared := map[string]bool{}
+	for _, p := range params {
+		declared[p.name] = true
+	}
+	used := map[string]bool{}
+	for _, name := range t.Params {
+		if !declared[name] {
+			return nil, fmt.Errorf("placeholder :%v has no param", name)
+		}
+		used[name] = true
+	}
+	for _, p := range params {
+		if !used[p.name] {
+			return nil, fmt.Errorf("param %v is not used", p.name)
+		}
+	}
+	return t.Params, nil
+}
+
+// addImports adds the import path of each package qualifier used by the
+// param types, as declared by `-- import:` or otherwise the qualifier.
+func addImports(imports map[string]bool, t *bqb.Template, params []param) {
+	declared := map[string]string{}
+	for _, imp := range t.Annotations["import"] {
+		imp = strings.Trim(imp, `"`)
+		declared[path.Base(imp)] = imp
+	}
+
+	for _, p := range params {
+		for _, m := range qualifierRe.FindAllStringSubmatch(p.typ, -1) {
+			if imp, ok := declared[m[1]]; ok {
+				imports[imp] = true
+			} else {
+				imports[m[1]] = true
+			}
+		}
+	}
+}
+
+// countPlaceholders counts the ? placeholders of sql the same way bqb
+// does, ignoring the ?? escape.
+func countPlaceholders(sql string) int {
+	return strings.Count(strings.ReplaceAll(sql, "??", ""), "?")
+}
+
+// exportedName converts a template name such as active_users to ActiveUsers.
+func exportedName(name string) string {
+	var builder strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nullism/bqb"
+)
+
+const querySql = `-- name: active_users
+-- description: the users active since a date.
+-- param: since time.Time
+-- param: ids []int
+SELECT * FROM users WHERE created > ? AND id IN (?) AND data ?? 'key'
+
+-- name: orders-by-user
+-- import: github.com/shopspring/decimal
+-- param: userID int64
+-- param: min decimal.Decimal
+SELECT * FROM orders WHERE user_id = :userID AND total > :min OR owner_id = :userID
+`
+
+const wantSrc = `// Code generated by bqbgen. DO NOT EDIT.
+
+package queries
+
+import (
+	"time"
+
+	"github.com/nullism/bqb"
+	"github.com/shopspring/decimal"
+)
+
+// ActiveUsers returns the users active since a date.
+func ActiveUsers(since time.Time, ids []int) *bqb.Query {
+	return bqb.New("SELECT * FROM users WHERE created > ? AND id IN (?) AND data ?? 'key'", since, ids)
+}
+
+// OrdersByUser returns the orders-by-user query.
+func OrdersByUser(userID int64, min decimal.Decimal) *bqb.Query {
+	return bqb.New("SELECT * FROM orders WHERE user_id = ? AND total > ? OR owner_id = ?", userID, min, userID)
+}
+`
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "queries.sql"), []byte(querySql), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "queries_gen.go")
+	if err := run(dir, "queries", out); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	src, _ := os.ReadFile(out)
+	if string(src) != wantSrc {
+		t.Errorf("\n got:\n%s\nwant:\n%s", src, wantSrc)
+	}
+
+	if err := run(filepath.Join(dir, "missing"), "queries", out); err == nil {
+		t.Errorf("expected error for missing dir")
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	tests := map[string]string{
+		"-- name: a\n-- param: id int\nSELECT ?, ?":                       "2 ? placeholders but 1 params",
+		"-- name: a\n-- param: id int\n-- param: b int\nSELECT ?":         "1 ? placeholders but 2 params",
+		"-- name: a\n-- param: id\nSELECT ?":                              "invalid param annotation",
+		"-- name: a\n-- param: id int\n-- param: id int\nSELECT ?, ?":     "duplicate param",
+		"-- name: a\n-- param: id int\nSELECT :id, :other":                "placeholder :other has no param",
+		"-- name: a\n-- param: id int\n-- param: b int\nSELECT :id":       "param b is not used",
+		"-- name: a\n-- param: id int\nSELECT ?\n-- name: b\nSELECT 1, ?": "0 params",
+	}
+	for text, wantErr := range tests {
+		templates, err := bqb.ParseTemplates(text)
+		if err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+		byName := map[string]*bqb.Template{}
+		for _, tpl := range templates {
+			byName[tpl.Name] = tpl
+		}
+
+		_, err = generate("queries", byName)
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("expected %q error, got: %v", wantErr, err)
+		}
+	}
+}
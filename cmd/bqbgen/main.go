@@ -0,0 +1,61 @@
+// Command bqbgen generates typed Go functions returning *bqb.Query from
+// annotated SQL files.
+//
+// Each named block declares its parameters in order with `-- param:` lines,
+// and any imports needed by their types with `-- import:` lines:
+//
+//	-- name: active_users
+//	-- param: since time.Time
+//	-- param: ids []int
+//	SELECT * FROM users WHERE created > ? AND id IN (?)
+//
+// generates
+//
+//	func ActiveUsers(since time.Time, ids []int) *bqb.Query
+//
+// Usage:
+//
+//	bqbgen -pkg queries -out queries_gen.go ./sql
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nullism/bqb"
+)
+
+func main() {
+	pkg := flag.String("pkg", "queries", "package name of the generated file")
+	out := flag.String("out", "", "output file (default stdout)")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: bqbgen [-pkg name] [-out file] <sql dir>")
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *pkg, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "bqbgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir, pkg, out string) error {
+	templates, err := bqb.LoadTemplates(os.DirFS(dir))
+	if err != nil {
+		return err
+	}
+
+	src, err := generate(pkg, templates)
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
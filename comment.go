@@ -0,0 +1,57 @@
+package bqb
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Comment adds key/value tags that are appended to the compiled SQL as a
+// comment following the sqlcommenter spec, e.g. `/*route='%2Fusers'*/`.
+// Calling Comment more than once merges the tags.
+// Note: Like hooks, comments are only rendered for the outermost Query.
+func (q *Query) Comment(tags map[string]string) *Query {
+	if q == nil {
+		q = Q()
+	}
+	if q.comments == nil {
+		q.comments = make(map[string]string, len(tags))
+	}
+	for k, v := range tags {
+		q.comments[k] = v
+	}
+	return q
+}
+
+// appendComment appends the sqlcommenter trailer for tags to sql.
+func appendComment(sql string, tags map[string]string) (string, error) {
+	if len(tags) == 0 {
+		return sql, nil
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		if strings.Contains(k, "*/") || strings.Contains(tags[k], "*/") {
+			return "", fmt.Errorf("invalid */ in comment tag: %v", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = commentEscape(k) + "='" + commentEscape(tags[k]) + "'"
+	}
+	comment := "/*" + strings.Join(pairs, ",") + "*/"
+
+	if strings.HasSuffix(sql, ";") {
+		return strings.TrimSuffix(sql, ";") + " " + comment + ";", nil
+	}
+	return sql + " " + comment, nil
+}
+
+// commentEscape url-encodes s, which also encodes the `'` meta character.
+func commentEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
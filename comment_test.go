@@ -0,0 +1,44 @@
+package bqb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuery_Comment(t *testing.T) {
+	q := New("SELECT * FROM users WHERE id = ?", 1).
+		Comment(map[string]string{"route": "/users/{id}", "traceparent": "00-abc-01"}).
+		Comment(map[string]string{"action": "it's here"})
+
+	sql, params, err := q.ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+
+	want := "SELECT * FROM users WHERE id = $1 " +
+		"/*action='it%27s%20here',route='%2Fusers%2F%7Bid%7D',traceparent='00-abc-01'*/"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if len(params) != 1 {
+		t.Errorf("got incorrect param count: %v", len(params))
+	}
+
+	// Comments of subqueries are not rendered
+	sql, _ = New("SELECT (?);", q).Comment(map[string]string{"a": "b"}).ToRaw()
+	want = "SELECT (SELECT * FROM users WHERE id = 1) /*a='b'*/;"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+
+	var qNil *Query
+	sql, _, _ = qNil.Comment(map[string]string{"a": "*/"}).Space("SELECT 1").ToSql()
+	if sql != "" {
+		t.Errorf("expected empty sql, got: %q", sql)
+	}
+
+	_, _, err = qNil.Comment(map[string]string{"a": "b */ DROP TABLE x"}).Space("SELECT 1").ToSql()
+	if err == nil || !strings.Contains(err.Error(), "*/") {
+		t.Errorf("expected error for */ in comment, got: %v", err)
+	}
+}
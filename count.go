@@ -0,0 +1,28 @@
+package bqb
+
+import "errors"
+
+// CountOf returns a Query counting the rows of q, as
+// `SELECT COUNT(*) FROM (q) AS sub`. The QueryParts of q labelled with
+// PaginateLabel or one of labels, such as an ORDER BY or LIMIT clause, are
+// left out of the count, and q itself is not changed.
+// The hooks, comments and policies of q are applied by the count Query,
+// since only the outermost Query applies them.
+func CountOf(q *Query, labels ...string) *Query {
+	if q == nil {
+		return errQuery(errors.New("cannot count nil Query"))
+	}
+
+	sub := *q
+	sub.Parts = append([]QueryPart{}, q.Parts...)
+	sub.hooks, sub.comments, sub.policies = nil, nil, nil
+	for _, label := range append([]string{PaginateLabel}, labels...) {
+		sub.Remove(label)
+	}
+
+	count := New("SELECT COUNT(*) FROM (?) ?", &sub, ByDialect{
+		ORACLE: New("sub"),
+		SQL:    New("AS sub"),
+	})
+	return outermost(count, q)
+}
@@ -0,0 +1,60 @@
+package bqb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCountOf(t *testing.T) {
+	where := Optional("WHERE").And("name = ?", "ed")
+	q := New("SELECT * FROM users ?", where).
+		Space("ORDER BY ?", Embedded("name")).Label("order").
+		Paginate(10, 20)
+
+	sql, params, err := CountOf(q, "order").ToPgsql()
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	want := "SELECT COUNT(*) FROM (SELECT * FROM users WHERE name = $1) AS sub"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{"ed"}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	sql, _, _ = CountOf(q).ToDialect(ORACLE)
+	want = "SELECT COUNT(*) FROM (SELECT * FROM users WHERE name = :1 ORDER BY name) sub"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+
+	// q is unchanged
+	sql, params, _ = q.ToPgsql()
+	if sql != "SELECT * FROM users WHERE name = $1 ORDER BY name LIMIT $2 OFFSET $3" || !reflect.DeepEqual(params, []any{"ed", 10, 20}) {
+		t.Errorf("unexpected sql: %q %v", sql, params)
+	}
+
+	if _, _, err := CountOf(nil).ToSql(); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestCountOf_Policy(t *testing.T) {
+	q := New("SELECT * FROM orders ?", Optional("WHERE").Guard("orders")).
+		Paginate(10, 0).
+		Comment(map[string]string{"route": "orders"}).
+		WithPolicy(Policy{Table: "orders", Predicate: New("tenant_id = ?", 7)})
+
+	sql, params, err := CountOf(q).ToSql()
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	want := "SELECT COUNT(*) FROM (SELECT * FROM orders WHERE (tenant_id = ?)) AS sub /*route='orders'*/"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{7}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+}
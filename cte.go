@@ -0,0 +1,153 @@
+package bqb
+
+import (
+	"errors"
+	"strings"
+)
+
+// CTE builds a WITH clause of common table expressions which is prepended
+// to a main Query.
+type CTE struct {
+	exprs     []cteExpr
+	recursive bool
+}
+
+type cteExpr struct {
+	name         string
+	columns      []string
+	materialized bool
+	query        *Query
+}
+
+// With returns a CTE builder containing the expression `name AS (q)`.
+func With(name string, q *Query) *CTE {
+	return (&CTE{}).With(name, q)
+}
+
+// With adds the expression `name AS (q)` to the CTE.
+func (c *CTE) With(name string, q *Query) *CTE {
+	c.exprs = append(c.exprs, cteExpr{name: name, query: q})
+	return c
+}
+
+// Columns sets the column list of the most recently added expression.
+func (c *CTE) Columns(cols ...string) *CTE {
+	if len(c.exprs) > 0 {
+		c.exprs[len(c.exprs)-1].columns = cols
+	}
+	return c
+}
+
+// Materialized marks the most recently added expression as MATERIALIZED.
+// Note: This is only supported by Postgres.
+func (c *CTE) Materialized() *CTE {
+	if len(c.exprs) > 0 {
+		c.exprs[len(c.exprs)-1].materialized = true
+	}
+	return c
+}
+
+// Recursive makes the clause a WITH RECURSIVE clause.
+func (c *CTE) Recursive() *CTE {
+	c.recursive = true
+	return c
+}
+
+// Prepend returns a new Query of the WITH clause followed by main, which
+// keeps the hooks, comments and policies of main.
+// Expressions with an empty query are skipped, as are expressions whose
+// name is not referenced by main or by another expression that is used.
+// main is returned unchanged if every expression is skipped.
+// Note: A name counts as referenced when it appears as a word anywhere in
+// the text of a query or its subqueries, including string literals.
+func (c *CTE) Prepend(main *Query) *Query {
+	if main == nil {
+		return errQuery(errors.New("cannot prepend CTE to nil Query"))
+	}
+
+	mainText := queryText(main)
+	texts := make([]string, len(c.exprs))
+	for i, e := range c.exprs {
+		texts[i] = queryText(e.query)
+	}
+
+	used := make([]bool, len(c.exprs))
+	referenced := func(i int) bool {
+		if mentions(mainText, c.exprs[i].name) {
+			return true
+		}
+		for j := range c.exprs {
+			if j != i && used[j] && mentions(texts[j], c.exprs[i].name) {
+				return true
+			}
+		}
+		return false
+	}
+	for changed := true; changed; {
+		changed = false
+		for i, e := range c.exprs {
+			if !used[i] && !e.query.Empty() && referenced(i) {
+				used[i], changed = true, true
+			}
+		}
+	}
+
+	exprs := Q()
+	for i, e := range c.exprs {
+		if !used[i] {
+			continue
+		}
+
+		text := e.name
+		if len(e.columns) > 0 {
+			text += " (" + strings.Join(e.columns, ",") + ")"
+		}
+		text += " AS "
+		if e.materialized {
+			text += "MATERIALIZED "
+		}
+		exprs.Comma(text+"(?)", e.query)
+	}
+
+	if exprs.Empty() {
+		return main
+	}
+
+	with := "WITH"
+	if c.recursive {
+		with = "WITH RECURSIVE"
+	}
+	return outermost(New(with+" ? ?", exprs, main), main)
+}
+
+// queryText returns the text of every QueryPart of q and its subqueries.
+func queryText(q *Query) string {
+	var b strings.Builder
+	q.Walk(func(_ string, part *QueryPart) {
+		b.WriteString(part.Text)
+		b.WriteString(" ")
+	})
+	return b.String()
+}
+
+// mentions reports whether text contains name as a word, ignoring case and
+// names qualified by a schema, e.g. `recent` in `JOIN recent r` but not in
+// `recent_orders` or `archive.recent`.
+func mentions(text, name string) bool {
+	if name == "" {
+		return false
+	}
+	text, name = strings.ToLower(text), strings.ToLower(name)
+	for i := strings.Index(text, name); i >= 0; {
+		end := i + len(name)
+		if (i == 0 || !isWordByte(text[i-1])) && (end == len(text) || text[end] == '.' || !isWordByte(text[end])) {
+			return true
+		}
+		next := strings.Index(text[i+1:], name)
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package bqb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWith(t *testing.T) {
+	main := New("SELECT * FROM recent JOIN totals USING (id) WHERE total > ?", 10)
+
+	q := With("recent", New("SELECT id FROM orders WHERE created > ?", "2024-01-01")).
+		Materialized().
+		With("unused", Optional("SELECT")).
+		With("totals", New("SELECT id, SUM(amount) FROM orders GROUP BY id")).
+		Columns("id", "total").
+		With("skipped", nil).
+		Prepend(main)
+
+	sql, params, err := q.ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+
+	want := "WITH recent AS MATERIALIZED (SELECT id FROM orders WHERE created > $1)," +
+		"totals (id,total) AS (SELECT id, SUM(amount) FROM orders GROUP BY id) " +
+		"SELECT * FROM recent JOIN totals USING (id) WHERE total > $2"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{"2024-01-01", 10}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+}
+
+func TestWith_Recursive(t *testing.T) {
+	tree := New("SELECT id, parent_id FROM nodes WHERE id = ?", 1).
+		Space("UNION ALL SELECT n.id, n.parent_id FROM nodes n JOIN tree t ON n.parent_id = t.id")
+
+	sql, _ := With("tree", tree).Recursive().Prepend(New("SELECT id FROM tree")).ToRaw()
+	want := "WITH RECURSIVE tree AS (SELECT id, parent_id FROM nodes WHERE id = 1 " +
+		"UNION ALL SELECT n.id, n.parent_id FROM nodes n JOIN tree t ON n.parent_id = t.id) SELECT id FROM tree"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+}
+
+func TestWith_Empty(t *testing.T) {
+	main := New("SELECT 1")
+	if q := With("a", Q()).Columns("x").Prepend(main); q != main {
+		t.Errorf("expected main query to be returned unchanged")
+	}
+
+	c := &CTE{}
+	if q := c.Columns("x").Materialized().Prepend(main); q != main {
+		t.Errorf("expected main query to be returned unchanged")
+	}
+}
+
+func TestWith_Unused(t *testing.T) {
+	tests := []struct {
+		cte  *CTE
+		main *Query
+		want string
+	}{
+		{
+			With("a", New("SELECT 1")).With("b", New("SELECT 2")),
+			New("SELECT * FROM b"),
+			"WITH b AS (SELECT 2) SELECT * FROM b",
+		},
+		{
+			With("a", New("SELECT 1")).With("b", New("SELECT * FROM a")).With("c", New("SELECT 3")),
+			New("SELECT * FROM t JOIN b ON b.id = t.id"),
+			"WITH a AS (SELECT 1),b AS (SELECT * FROM a) SELECT * FROM t JOIN b ON b.id = t.id",
+		},
+		{
+			With("tree", New("SELECT 1 UNION ALL SELECT n FROM tree")).Recursive(),
+			New("SELECT * FROM tree_nodes JOIN archive.tree USING (id)"),
+			"SELECT * FROM tree_nodes JOIN archive.tree USING (id)",
+		},
+		{
+			With("a", New("SELECT 1")),
+			New("SELECT * FROM t WHERE ?", ByDialect{PGSQL: New("id IN (SELECT * FROM A)")}),
+			"WITH a AS (SELECT 1) SELECT * FROM t WHERE id IN (SELECT * FROM A)",
+		},
+	}
+	for _, tt := range tests {
+		sql, _, err := tt.cte.Prepend(tt.main).ToPgsql()
+		if err != nil {
+			t.Errorf("got error: %v", err)
+		}
+		if sql != tt.want {
+			t.Errorf("\n got: %q\nwant: %q", sql, tt.want)
+		}
+	}
+}
+
+func TestWith_Outermost(t *testing.T) {
+	tenant := Policy{Table: "orders", Predicate: New("tenant_id = ?", 7)}
+	main := New("SELECT * FROM recent JOIN orders USING (id)").
+		Comment(map[string]string{"route": "orders"}).
+		WithPolicy(tenant)
+
+	q := With("recent", New("SELECT id FROM events")).Prepend(main)
+	if _, _, err := q.ToSql(); err == nil || !strings.Contains(err.Error(), "guarded table orders") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	main.Space("?", Optional("WHERE").Guard("orders"))
+	sql, params, err := With("recent", New("SELECT id FROM events")).Prepend(main).ToSql()
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	want := "WITH recent AS (SELECT id FROM events) SELECT * FROM recent JOIN orders USING (id) WHERE (tenant_id = ?) /*route='orders'*/"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{7}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	if _, _, err := With("recent", New("SELECT 1")).Prepend(nil).ToSql(); err == nil {
+		t.Error("expected error for nil main query")
+	}
+}
@@ -0,0 +1,292 @@
+package bqb
+
+import (
+	"bytes"
+	"strings"
+)
+
+// FormatOptions controls the output of ToSqlFormatted.
+type FormatOptions struct {
+	// Dialect of the placeholders, SQL when empty.
+	Dialect Dialect
+	// Indent is written once per indentation level, two spaces when empty.
+	Indent string
+}
+
+// PrintFormatted makes Print and PrintTo write the SQL formatted with the
+// default FormatOptions.
+var PrintFormatted = false
+
+// ToSqlFormatted returns the sql for opts.Dialect re-indented for reading,
+// with each clause such as SELECT, FROM and WHERE on its own line and comma
+// lists and AND/OR conditions aligned beneath it. Quoted text and comments
+// are left untouched.
+func (q *Query) ToSqlFormatted(opts FormatOptions) (string, []any, error) {
+	if opts.Dialect == "" {
+		opts.Dialect = SQL
+	}
+	sql, params, err := q.build(opts.Dialect)
+	if err != nil {
+		return "", nil, err
+	}
+	return formatSql(sql, opts.Indent), params, nil
+}
+
+// blockKeywords start a clause whose contents are indented on the
+// following lines.
+var blockKeywords = []string{
+	"SELECT DISTINCT", "SELECT", "FROM", "WHERE", "GROUP BY", "HAVING",
+	"ORDER BY", "SET", "VALUES", "RETURNING",
+}
+
+// lineKeywords start a clause whose contents follow on the same line.
+var lineKeywords = []string{
+	"WITH RECURSIVE", "WITH", "INSERT INTO", "UPDATE", "DELETE FROM",
+	"UNION ALL", "UNION", "INTERSECT", "EXCEPT", "LIMIT", "OFFSET", "FETCH",
+	"ON CONFLICT", "ON DUPLICATE KEY UPDATE", "OUTPUT",
+}
+
+// joinKeywords start a join within a FROM clause.
+var joinKeywords = []string{
+	"LEFT OUTER JOIN", "RIGHT OUTER JOIN", "FULL OUTER JOIN", "LEFT JOIN",
+	"RIGHT JOIN", "FULL JOIN", "INNER JOIN", "CROSS JOIN", "JOIN",
+}
+
+type sqlToken struct {
+	text        string
+	spaceBefore bool
+}
+
+type formatter struct {
+	tokens  []sqlToken
+	indent  string
+	out     []byte
+	base    int
+	content int
+	// inline counts the open parentheses that are not subqueries, within
+	// which the sql is left as is.
+	inline  int
+	parens  []formatParen
+	between bool
+	newline bool
+}
+
+type formatParen struct {
+	subquery bool
+	base     int
+	content  int
+}
+
+func formatSql(sql, indent string) string {
+	if indent == "" {
+		indent = "  "
+	}
+	f := &formatter{tokens: tokenizeSql(sql), indent: indent}
+	f.format()
+	return string(f.out)
+}
+
+func (f *formatter) format() {
+	for i := 0; i < len(f.tokens); i++ {
+		tok := f.tokens[i]
+		upper := strings.ToUpper(tok.text)
+
+		if f.inline > 0 {
+			switch tok.text {
+			case "(":
+				f.openParen(i)
+			case ")":
+				f.closeParen()
+			default:
+				f.write(tok)
+			}
+			continue
+		}
+
+		if kw, n := f.matchKeyword(i, blockKeywords); n > 0 {
+			f.lineBreak(f.base)
+			f.writeText(kw)
+			f.content = f.base + 1
+			f.lineBreak(f.content)
+			i += n - 1
+			continue
+		}
+		if kw, n := f.matchKeyword(i, lineKeywords); n > 0 {
+			f.lineBreak(f.base)
+			f.writeText(kw)
+			f.content = f.base
+			i += n - 1
+			continue
+		}
+		if kw, n := f.matchKeyword(i, joinKeywords); n > 0 {
+			f.lineBreak(f.base + 1)
+			f.writeText(kw)
+			i += n - 1
+			continue
+		}
+
+		switch {
+		case upper == "BETWEEN":
+			f.between = true
+			f.write(tok)
+		case (upper == "AND" || upper == "OR") && !f.between:
+			f.lineBreak(f.content)
+			f.writeText(tok.text)
+		case upper == "AND":
+			f.between = false
+			f.write(tok)
+		case tok.text == ",":
+			f.writeText(",")
+			f.lineBreak(f.content)
+		case tok.text == ";":
+			f.writeText(";")
+			f.base, f.content = 0, 0
+			f.lineBreak(0)
+		case tok.text == "(":
+			f.openParen(i)
+		case tok.text == ")":
+			f.closeParen()
+		case strings.HasPrefix(tok.text, "--"):
+			f.write(tok)
+			f.lineBreak(f.content)
+		default:
+			f.write(tok)
+		}
+	}
+}
+
+// matchKeyword returns the keyword of keywords starting at token i as
+// written in the sql, and the number of tokens it spans.
+func (f *formatter) matchKeyword(i int, keywords []string) (string, int) {
+	for _, kw := range keywords {
+		words := strings.Fields(kw)
+		if i+len(words) > len(f.tokens) {
+			continue
+		}
+		match := true
+		for j, w := range words {
+			if !strings.EqualFold(f.tokens[i+j].text, w) {
+				match = false
+				break
+			}
+		}
+		if match {
+			texts := make([]string, len(words))
+			for j := range words {
+				texts[j] = f.tokens[i+j].text
+			}
+			return strings.Join(texts, " "), len(words)
+		}
+	}
+	return "", 0
+}
+
+func (f *formatter) openParen(i int) {
+	f.write(f.tokens[i])
+	subquery := false
+	if f.inline == 0 && i+1 < len(f.tokens) {
+		next := strings.ToUpper(f.tokens[i+1].text)
+		subquery = next == "SELECT" || next == "WITH" || next == "VALUES"
+	}
+
+	f.parens = append(f.parens, formatParen{subquery: subquery, base: f.base, content: f.content})
+	if subquery {
+		f.base = f.content + 1
+		f.content = f.base
+	} else {
+		f.inline++
+	}
+}
+
+func (f *formatter) closeParen() {
+	if len(f.parens) == 0 {
+		f.writeText(")")
+		return
+	}
+	p := f.parens[len(f.parens)-1]
+	f.parens = f.parens[:len(f.parens)-1]
+	if p.subquery {
+		f.base, f.content = p.base, p.content
+		f.lineBreak(f.content)
+	} else {
+		f.inline--
+	}
+	f.out = append(f.out, ')')
+	f.newline = false
+}
+
+func (f *formatter) lineBreak(level int) {
+	if len(f.out) == 0 {
+		return
+	}
+	f.out = bytes.TrimRight(f.out, " \t")
+	if f.out[len(f.out)-1] != '\n' {
+		f.out = append(f.out, '\n')
+	}
+	f.out = append(f.out, strings.Repeat(f.indent, level)...)
+	f.newline = true
+}
+
+func (f *formatter) write(tok sqlToken) {
+	if tok.spaceBefore && !f.newline && len(f.out) > 0 {
+		f.out = append(f.out, ' ')
+	}
+	f.out = append(f.out, tok.text...)
+	f.newline = false
+}
+
+func (f *formatter) writeText(text string) {
+	f.write(sqlToken{text: text, spaceBefore: text != "," && text != ";"})
+}
+
+// tokenizeSql splits sql into words, quoted text, comments and single
+// punctuation characters, noting whether each was preceded by whitespace.
+func tokenizeSql(sql string) []sqlToken {
+	var tokens []sqlToken
+	space := false
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			i++
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			i++
+			for i < len(sql) {
+				if sql[i] == c {
+					if i+1 < len(sql) && sql[i+1] == c {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			i += end
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 4
+			}
+		case isWordByte(c) || c == '$' || c == '@' || c == '#' || c >= 0x80:
+			for i < len(sql) && (isWordByte(sql[i]) || sql[i] == '$' || sql[i] == '@' || sql[i] == '#' || sql[i] >= 0x80) {
+				i++
+			}
+		default:
+			i++
+		}
+		tokens = append(tokens, sqlToken{text: sql[start:i], spaceBefore: space})
+		space = false
+	}
+	return tokens
+}
@@ -0,0 +1,102 @@
+package bqb
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuery_ToSqlFormatted(t *testing.T) {
+	sub := New("SELECT id FROM orders WHERE total BETWEEN ? AND ? OR refunded", 10, 20)
+	q := With("recent", New("SELECT id FROM orders WHERE created > ?", "2024-01-01")).Prepend(
+		New("SELECT u.id, COUNT(*) AS c, name::text FROM users u").
+			Space("LEFT JOIN recent r ON r.id = u.id").
+			Space("WHERE u.note = 'a, b AND c' AND (u.age > ? OR u.age IS NULL)", 21).
+			And("u.id IN (?)", sub).
+			Space("GROUP BY u.id, name ORDER BY c DESC").
+			Paginate(10, 0),
+	)
+
+	sql, params, err := q.ToSqlFormatted(FormatOptions{Dialect: PGSQL})
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+
+	want := `WITH recent AS (
+  SELECT
+    id
+  FROM
+    orders
+  WHERE
+    created > $1
+)
+SELECT
+  u.id,
+  COUNT(*) AS c,
+  name::text
+FROM
+  users u
+  LEFT JOIN recent r ON r.id = u.id
+WHERE
+  u.note = 'a, b AND c'
+  AND (u.age > $2 OR u.age IS NULL)
+  AND u.id IN (
+    SELECT
+      id
+    FROM
+      orders
+    WHERE
+      total BETWEEN $3 AND $4
+      OR refunded
+  )
+GROUP BY
+  u.id,
+  name
+ORDER BY
+  c DESC
+LIMIT $5
+OFFSET $6`
+	if sql != want {
+		t.Errorf("\n got:\n%s\nwant:\n%s", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{"2024-01-01", 21, 10, 20, 10, 0}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+}
+
+func TestQuery_ToSqlFormatted_Options(t *testing.T) {
+	q := New("insert into t (a, b) values (?, ?) -- note, and more\n/* keep, this */ on conflict (a) do nothing; select 1)", 1, "x")
+
+	sql, _, err := q.ToSqlFormatted(FormatOptions{Indent: "\t"})
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	want := "insert into t (a, b)\nvalues\n\t(?, ?) -- note, and more\n\t/* keep, this */\non conflict (a) do nothing;\nselect\n\t1 )"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+
+	_, _, err = New("?").ToSqlFormatted(FormatOptions{})
+	if err == nil {
+		t.Errorf("expected error for extra ?")
+	}
+
+	sql = formatSql(`SELECT "a""b", 'unterminated`, "")
+	want = "SELECT\n  \"a\"\"b\",\n  'unterminated"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+}
+
+func TestPrintFormatted(t *testing.T) {
+	defer func() { PrintFormatted = false }()
+	PrintFormatted = true
+
+	var buf bytes.Buffer
+	New("SELECT a, b FROM t WHERE id = ?", 1).PrintTo(&buf)
+	want := "SQL: SELECT\n  a,\n  b\nFROM\n  t\nWHERE\n  id = ?\n"
+	if !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("\n got: %q\nwant: %q", buf.String(), want)
+	}
+}
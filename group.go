@@ -0,0 +1,54 @@
+package bqb
+
+// All joins the non-empty queries with ' AND ', wrapping each member and the
+// group in parentheses. An empty Query is returned when every member is
+// empty.
+func All(queries ...*Query) *Query {
+	return group(" AND ", queries)
+}
+
+// Any joins the non-empty queries with ' OR ', wrapping each member and the
+// group in parentheses. An empty Query is returned when every member is
+// empty.
+func Any(queries ...*Query) *Query {
+	return group(" OR ", queries)
+}
+
+// Not negates the query as `NOT (query)`, or returns an empty Query when
+// the query is empty. A group is not wrapped again, e.g. Not(All(a, b))
+// renders `NOT ((a) AND (b))`.
+func Not(query *Query) *Query {
+	if query.Empty() {
+		return Q()
+	}
+	text := "NOT (?)"
+	if query.grouped && query.Len() == 1 {
+		text = "NOT ?"
+	}
+	q := New(text, query)
+	q.grouped = true
+	return q
+}
+
+// group joins the queries with sep. Members are wrapped in parentheses
+// unless they are an unchanged group already, so that a single part such as
+// `a = 1 OR b = 2` keeps its meaning.
+func group(sep string, queries []*Query) *Query {
+	q := Q()
+	for _, child := range queries {
+		if child.Empty() {
+			continue
+		}
+		if child.grouped && child.Len() == 1 {
+			q.Join(sep, "?", child)
+		} else {
+			q.Join(sep, "(?)", child)
+		}
+	}
+
+	if q.Len() > 1 {
+		q = New("(?)", q)
+	}
+	q.grouped = q.Len() == 1
+	return q
+}
@@ -0,0 +1,100 @@
+package bqb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	where := Optional("WHERE")
+	where.And("?", All(
+		New("name = ?", "a"),
+		Q(),
+		nil,
+		Any(New("age > ?", 20), New("age IS NULL")),
+		New("x = 1").Or("y = 2"),
+	))
+
+	sql, params, err := New("SELECT * FROM users ?", where).ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+
+	want := "SELECT * FROM users WHERE ((name = $1) AND ((age > $2) OR (age IS NULL)) AND (x = 1 OR y = 2))"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{"a", 20}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	sql, _ = All(New("a = 1"), Optional("WHERE")).ToRaw()
+	if sql != "(a = 1)" {
+		t.Errorf("got: %q, want: %q", sql, "(a = 1)")
+	}
+
+	sql, _ = All(New("a = 1 OR b = 2"), New("c = 3")).ToRaw()
+	want = "((a = 1 OR b = 2) AND (c = 3))"
+	if sql != want {
+		t.Errorf("got: %q, want: %q", sql, want)
+	}
+
+	// a group changed after it was built is wrapped again
+	sql, _ = Any(All(New("a = 1"), New("b = 2")).And("c = 3"), New("d = 4")).ToRaw()
+	want = "((((a = 1) AND (b = 2)) AND c = 3) OR (d = 4))"
+	if sql != want {
+		t.Errorf("got: %q, want: %q", sql, want)
+	}
+}
+
+func TestAll_Empty(t *testing.T) {
+	if !All().Empty() || !Any(Q(), nil).Empty() || !Not(All(Q())).Empty() {
+		t.Errorf("expected empty groups")
+	}
+
+	where := Optional("WHERE")
+	where.And("?", All(Any(Q()), Not(nil)))
+	if !where.Empty() {
+		t.Errorf("expected where to remain empty")
+	}
+
+	where.And("(?)", Any()).Or("NOT (?)", All(Q()))
+	if !where.Empty() {
+		t.Errorf("expected where to remain empty")
+	}
+
+	sql, _ := New("SELECT * FROM users").Space("?", where).ToRaw()
+	want := "SELECT * FROM users"
+	if sql != want {
+		t.Errorf("got: %q, want: %q", sql, want)
+	}
+}
+
+func TestNot(t *testing.T) {
+	sql, params, _ := Any(Not(New("a = ?", 1)), New("b = ?", 2)).ToSql()
+	want := "(NOT (a = ?) OR (b = ?))"
+	if sql != want {
+		t.Errorf("got: %q, want: %q", sql, want)
+	}
+	if len(params) != 2 {
+		t.Errorf("got incorrect param count: %v", len(params))
+	}
+}
+
+func TestNot_Group(t *testing.T) {
+	tests := []struct {
+		q    *Query
+		want string
+	}{
+		{Not(All(New("a = 1"), New("b = 2"))), "NOT ((a = 1) AND (b = 2))"},
+		{Not(Any(New("a = 1"))), "NOT (a = 1)"},
+		{Not(New("a = 1").Or("b = 2")), "NOT (a = 1 OR b = 2)"},
+		{Not(Not(New("a = 1"))), "NOT NOT (a = 1)"},
+	}
+	for _, tt := range tests {
+		sql, _ := tt.q.ToRaw()
+		if sql != tt.want {
+			t.Errorf("got: %q, want: %q", sql, tt.want)
+		}
+	}
+}
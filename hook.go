@@ -0,0 +1,80 @@
+package bqb
+
+import (
+	"sync"
+	"time"
+)
+
+// BuildEvent describes a compiled Query and is passed to each Hook.
+type BuildEvent struct {
+	Dialect Dialect
+	SQL     string
+	Params  []any
+	Elapsed time.Duration
+	Err     error
+}
+
+// Hook is invoked whenever a Query is compiled by ToSql, ToPgsql, ToMysql
+// or ToRaw. A Hook may rewrite the SQL and Params of the event, and a
+// returned error aborts the compilation.
+// Note: Hooks are not invoked for subqueries, only the outermost Query.
+type Hook interface {
+	AfterBuild(e *BuildEvent) error
+}
+
+// HookFunc adapts an ordinary function to the Hook interface.
+type HookFunc func(e *BuildEvent) error
+
+// AfterBuild calls f(e).
+func (f HookFunc) AfterBuild(e *BuildEvent) error {
+	return f(e)
+}
+
+var (
+	hooksMu     sync.RWMutex
+	globalHooks []Hook
+)
+
+// AddHook registers a Hook that is invoked for every Query.
+func AddHook(h Hook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	globalHooks = append(globalHooks, h)
+}
+
+// ClearHooks removes all hooks registered with AddHook.
+func ClearHooks() {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	globalHooks = nil
+}
+
+// WithHook registers a Hook that is invoked only when this Query is
+// compiled, after any global hooks.
+func (q *Query) WithHook(h Hook) *Query {
+	if q == nil {
+		q = Q()
+	}
+	q.hooks = append(q.hooks, h)
+	return q
+}
+
+func (q *Query) runHooks(e *BuildEvent) (string, []any, error) {
+	hooksMu.RLock()
+	hooks := append([]Hook{}, globalHooks...)
+	hooksMu.RUnlock()
+	if q != nil {
+		hooks = append(hooks, q.hooks...)
+	}
+
+	for _, h := range hooks {
+		if err := h.AfterBuild(e); err != nil && e.Err == nil {
+			e.Err = err
+		}
+	}
+
+	if e.Err != nil {
+		return "", nil, e.Err
+	}
+	return e.SQL, e.Params, nil
+}
@@ -0,0 +1,100 @@
+package bqb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAddHook(t *testing.T) {
+	defer ClearHooks()
+
+	var events []BuildEvent
+	AddHook(HookFunc(func(e *BuildEvent) error {
+		events = append(events, *e)
+		return nil
+	}))
+
+	q := New("SELECT * FROM users WHERE id = ?", 1)
+	if _, _, err := q.ToPgsql(); err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	if _, err := q.ToRaw(); err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	_, _, _ = New("?").ToMysql()
+
+	if len(events) != 3 {
+		t.Fatalf("want 3 events, got %v", len(events))
+	}
+
+	want := "SELECT * FROM users WHERE id = $1"
+	if events[0].Dialect != PGSQL || events[0].SQL != want {
+		t.Errorf("got: %q %q, want: %q", events[0].Dialect, events[0].SQL, want)
+	}
+	if !reflect.DeepEqual(events[0].Params, []any{1}) {
+		t.Errorf("unexpected params: %v", events[0].Params)
+	}
+	if events[0].Elapsed < 0 {
+		t.Errorf("expected non-negative elapsed time")
+	}
+
+	want = "SELECT * FROM users WHERE id = 1"
+	if events[1].Dialect != RAW || events[1].SQL != want || events[1].Params != nil {
+		t.Errorf("unexpected raw event: %+v", events[1])
+	}
+
+	if events[2].Err == nil {
+		t.Errorf("expected build error in event")
+	}
+
+	ClearHooks()
+	_, _, _ = q.ToSql()
+	if len(events) != 3 {
+		t.Errorf("hook invoked after ClearHooks")
+	}
+}
+
+func TestQuery_WithHook(t *testing.T) {
+	defer ClearHooks()
+
+	AddHook(HookFunc(func(e *BuildEvent) error {
+		e.SQL = "/* tenant=1 */ " + e.SQL
+		return nil
+	}))
+
+	count := 0
+	q := New("SELECT 1").WithHook(HookFunc(func(e *BuildEvent) error {
+		count++
+		e.SQL += " -- counted"
+		return nil
+	}))
+
+	sql, _, err := q.ToSql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	want := "/* tenant=1 */ SELECT 1 -- counted"
+	if sql != want {
+		t.Errorf("got: %q, want: %q", sql, want)
+	}
+
+	// Hooks of a subquery are not invoked
+	_, _, _ = New("SELECT (?)", q).ToSql()
+	if count != 1 {
+		t.Errorf("want 1 hook call, got %v", count)
+	}
+
+	var qNil *Query
+	qNil = qNil.WithHook(HookFunc(func(e *BuildEvent) error {
+		return errors.New("hook failure")
+	}))
+	qNil.Space("SELECT 1")
+	sql, params, err := qNil.ToSql()
+	if err == nil || err.Error() != "hook failure" {
+		t.Errorf("expected hook failure, got: %v", err)
+	}
+	if sql != "" || params != nil {
+		t.Errorf("expected empty result on hook error, got: %q %v", sql, params)
+	}
+}
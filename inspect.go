@@ -0,0 +1,116 @@
+package bqb
+
+import (
+	"sort"
+	"strconv"
+)
+
+// WalkFunc is called by Walk for each QueryPart with its path.
+type WalkFunc func(path string, part *QueryPart)
+
+// Label sets the label of the last QueryPart added to the Query, so it can
+// be found with Find or Walk, e.g. q.And("tenant_id = ?", id).Label("where.tenant").
+// Nothing is labelled when the last call added no QueryPart, e.g. AndIf
+// with a false cond, AndPtr with a nil pointer, or Remove.
+func (q *Query) Label(label string) *Query {
+	switch {
+	case q == nil || q.last < 0:
+	case q.last > 0 && q.last <= len(q.Parts):
+		q.Parts[q.last-1].Label = label
+	case len(q.Parts) > 0:
+		q.Parts[len(q.Parts)-1].Label = label
+	}
+	return q
+}
+
+// Find returns the first QueryPart with label, searching nested subqueries
+// depth first, or nil if there is none or label is empty.
+func (q *Query) Find(label string) *QueryPart {
+	if label == "" {
+		return nil
+	}
+	var found *QueryPart
+	q.Walk(func(_ string, part *QueryPart) {
+		if found == nil && part.Label == label {
+			found = part
+		}
+	})
+	return found
+}
+
+// Walk calls fn for each QueryPart of the Query, followed by the parts of
+// any subqueries it was given as arguments, including those wrapped by
+// Secret or ByDialect. The path of a part is its label, or its index when
+// unlabelled, prefixed by the path of its parent part, e.g.
+// "where/0/where.tenant". The queries of a ByDialect are prefixed by their
+// Dialect, e.g. "0/postgres/0".
+//
+// Note: The text of a part is built when the part is added, so subqueries
+// are visited as they were then, matching the compiled SQL. Guarded and
+// ByDialect queries are rendered at compile time, so they are visited as
+// they are now.
+func (q *Query) Walk(fn WalkFunc) {
+	q.walk("", fn)
+}
+
+func (q *Query) walk(parent string, fn WalkFunc) {
+	if q == nil {
+		return
+	}
+	for i := range q.Parts {
+		part := &q.Parts[i]
+		path := part.Label
+		if path == "" {
+			path = strconv.Itoa(i)
+		}
+		if parent != "" {
+			path = parent + "/" + path
+		}
+		fn(path, part)
+		for _, sub := range part.subqueries {
+			subPath := path
+			if sub.name != "" {
+				subPath += "/" + sub.name
+			}
+			sub.query.walk(subPath, fn)
+		}
+	}
+}
+
+// subquery is a Query given as an argument, visited by Walk under name.
+type subquery struct {
+	name  string
+	query *Query
+}
+
+// subqueriesOf returns the queries in arg visited by Walk.
+func subqueriesOf(arg any) []subquery {
+	switch v := arg.(type) {
+	case *Query:
+		if v == nil {
+			return nil
+		}
+		if v.guarded {
+			return []subquery{{query: v}}
+		}
+		snapshot := *v
+		snapshot.Parts = append([]QueryPart{}, v.Parts...)
+		return []subquery{{query: &snapshot}}
+	case Redacted:
+		return subqueriesOf(v.value)
+	case ByDialect:
+		dialects := make([]string, 0, len(v))
+		for d := range v {
+			dialects = append(dialects, string(d))
+		}
+		sort.Strings(dialects)
+		var subs []subquery
+		for _, d := range dialects {
+			if q := v[Dialect(d)]; q != nil {
+				subs = append(subs, subquery{name: d, query: q})
+			}
+		}
+		return subs
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package bqb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLabel(t *testing.T) {
+	q := Optional("WHERE").Label("ignored")
+	q.And("a = 1").And("b = ?", 2).Label("where.b")
+
+	if q.Parts[0].Label != "" || q.Parts[1].Label != "where.b" {
+		t.Errorf("unexpected labels: %q, %q", q.Parts[0].Label, q.Parts[1].Label)
+	}
+
+	sql, params, _ := q.ToSql()
+	if sql != "WHERE a = 1 AND b = ?" || !reflect.DeepEqual(params, []any{2}) {
+		t.Errorf("unexpected sql: %q %v", sql, params)
+	}
+}
+
+func TestLabel_Skipped(t *testing.T) {
+	var status *string
+	where := Optional("WHERE").
+		And("tenant_id = ?", 1).Label("where.tenant").
+		AndIf(false, "status = ?", "x").Label("where.status").
+		AndPtr("status = ?", status).Label("where.status").
+		And("(?)", All()).Label("where.status").
+		Or("deleted = ?", false).Label("where.deleted")
+	where.Insert(1, " AND ", "region = ?", "eu").Label("where.region")
+
+	where.Remove("where.status")
+	sql, params, _ := where.ToSql()
+	if sql != "WHERE tenant_id = ? AND region = ? OR deleted = ?" || !reflect.DeepEqual(params, []any{1, "eu", false}) {
+		t.Errorf("unexpected sql: %q %v", sql, params)
+	}
+	if where.Find("where.tenant") == nil || where.Find("where.region") == nil || where.Find("where.deleted") == nil {
+		t.Error("expected labels to be kept")
+	}
+
+	where.Remove("where.region").Label("where.status")
+	if where.Find("where.status") != nil {
+		t.Error("expected Label after Remove to be ignored")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	where := Optional("WHERE")
+	where.And("deleted_at IS NULL")
+	where.And("?", All(New("tenant_id = ?", 1).Label("where.tenant"))).Label("where.filters")
+
+	sub := New("SELECT user_id FROM admins").Label("from.admins")
+	q := New("SELECT * FROM users ?", where).Label("select")
+	q.Space("UNION ?", sub)
+
+	var paths []string
+	q.Walk(func(path string, part *QueryPart) {
+		paths = append(paths, path)
+	})
+
+	want := []string{
+		"select",
+		"select/0",
+		"select/where.filters",
+		"select/where.filters/0",
+		"select/where.filters/0/where.tenant",
+		"1",
+		"1/from.admins",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("\n got: %q\nwant: %q", paths, want)
+	}
+
+	var nilQuery *Query
+	nilQuery.Walk(func(string, *QueryPart) {
+		t.Error("unexpected call")
+	})
+}
+
+func TestFind(t *testing.T) {
+	where := Optional("WHERE").And("tenant_id = ?", 7).Label("where.tenant")
+	q := New("SELECT * FROM users ?", where).Space("LIMIT 10").Label("limit")
+
+	part := q.Find("where.tenant")
+	if part == nil || part.Text != "tenant_id = "+paramPh || !reflect.DeepEqual(part.Params, []any{7}) {
+		t.Errorf("unexpected part: %+v", part)
+	}
+
+	if part := q.Find("limit"); part != &q.Parts[1] {
+		t.Errorf("unexpected part: %+v", part)
+	}
+
+	if part := q.Find("missing"); part != nil {
+		t.Errorf("unexpected part: %+v", part)
+	}
+}
+
+func TestWalk_Bound(t *testing.T) {
+	sub := New("SELECT * FROM t")
+	q := New("?", sub)
+	sub.Label("late").Space("WHERE x = 1").Label("later")
+
+	if q.Find("late") != nil || q.Find("later") != nil {
+		t.Error("expected subquery to be visited as it was bound")
+	}
+	if sql, _, _ := q.ToSql(); sql != "SELECT * FROM t" {
+		t.Errorf("unexpected sql: %q", sql)
+	}
+
+	// guarded clauses are rendered at compile time
+	where := Optional("WHERE").Guard()
+	q = New("SELECT * FROM t ?", where)
+	where.And("tenant_id = ?", 1).Label("where.tenant")
+	if q.Find("where.tenant") == nil {
+		t.Error("expected guarded clause to be visited")
+	}
+}
+
+func TestWalk_Wrapped(t *testing.T) {
+	q := New("SELECT ? FROM t WHERE ?",
+		ByDialect{PGSQL: New("NOW()").Label("now"), SQL: New("CURRENT_TIMESTAMP")},
+		Secret(New("token = ?", "x").Label("token")),
+	)
+
+	var paths []string
+	q.Walk(func(path string, part *QueryPart) {
+		paths = append(paths, path)
+	})
+
+	want := []string{"0", "0/postgres/now", "0/sql/0", "0/token"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("\n got: %q\nwant: %q", paths, want)
+	}
+}
@@ -0,0 +1,153 @@
+package bqb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JsonMarshaler encodes the values bound as JSON. See SetJsonMarshaler.
+type JsonMarshaler func(v any) ([]byte, error)
+
+var jsonMarshal JsonMarshaler = json.Marshal
+
+// SetJsonMarshaler replaces the encoder used for Json, JsonMap, JsonList
+// and JsonContains, e.g. with a faster drop-in for encoding/json. Passing
+// nil restores json.Marshal.
+// Note: This is not safe to call while queries are being built.
+func SetJsonMarshaler(m JsonMarshaler) {
+	if m == nil {
+		m = json.Marshal
+	}
+	jsonMarshal = m
+}
+
+// JsonValue binds a value of any type as its JSON encoding. See Json.
+type JsonValue[T any] struct {
+	value T
+}
+
+// Json returns a JsonValue that binds v as a JSON string, like JsonMap
+// does for maps.
+func Json[T any](v T) JsonValue[T] {
+	return JsonValue[T]{value: v}
+}
+
+func (j JsonValue[T]) jsonValue() any {
+	return j.value
+}
+
+// jsonValuer is implemented by every JsonValue type.
+type jsonValuer interface {
+	jsonValue() any
+}
+
+type jsonPath struct {
+	col  string
+	keys []string
+}
+
+func (j jsonPath) render(dialect Dialect) (*Query, error) {
+	if len(j.keys) == 0 {
+		return New(j.col), nil
+	}
+
+	switch dialect {
+	case PGSQL:
+		keys := make([]any, len(j.keys))
+		for i, k := range j.keys {
+			keys[i] = k
+		}
+		text := j.col + strings.Repeat("->?", len(j.keys)-1) + "->>?"
+		return New(text, keys...), nil
+	case MSSQL, ORACLE:
+		return New("JSON_VALUE("+j.col+", ?)", jsonPathString(j.keys)), nil
+	default:
+		return New("JSON_EXTRACT("+j.col+", ?)", jsonPathString(j.keys)), nil
+	}
+}
+
+type jsonContains struct {
+	col   string
+	value any
+}
+
+func (j jsonContains) render(dialect Dialect) (*Query, error) {
+	bytes, err := jsonMarshal(j.value)
+	if err != nil {
+		return nil, fmt.Errorf("cann jsonify struct: %v", err)
+	}
+
+	switch dialect {
+	case PGSQL:
+		return New(j.col+" @> ?::jsonb", string(bytes)), nil
+	case MSSQL, ORACLE:
+		return nil, fmt.Errorf("JsonContains is not supported for dialect: %v", dialect)
+	default:
+		return New("JSON_CONTAINS("+j.col+", ?)", string(bytes)), nil
+	}
+}
+
+type jsonHasKey struct {
+	col string
+	key string
+}
+
+func (j jsonHasKey) render(dialect Dialect) (*Query, error) {
+	switch dialect {
+	case PGSQL:
+		return New(j.col+" ?? ?", j.key), nil
+	case MSSQL, ORACLE:
+		return nil, fmt.Errorf("JsonHasKey is not supported for dialect: %v", dialect)
+	default:
+		return New("JSON_CONTAINS_PATH("+j.col+", 'one', ?)", jsonPathString([]string{j.key})), nil
+	}
+}
+
+// JsonPath extracts the value at keys from the JSON column col as text,
+// rendered as `col->'a'->>'b'` for Postgres and `JSON_EXTRACT(col, '$.a.b')`
+// for MySQL. The keys are bound as parameters.
+// Note: Like Embedded, col is not to be used for untrusted input.
+func JsonPath(col string, keys ...string) *Query {
+	return New("?", jsonPath{col: col, keys: keys})
+}
+
+// JsonContains tests if the JSON column col contains the JSON encoding of
+// v, rendered as `col @> ?::jsonb` for Postgres and `JSON_CONTAINS(col, ?)`
+// for MySQL.
+// Note: Like Embedded, col is not to be used for untrusted input.
+func JsonContains(col string, v any) *Query {
+	return New("?", jsonContains{col: col, value: v})
+}
+
+// JsonHasKey tests if the JSON object in col has the top level key,
+// rendered as `col ? key` for Postgres without the need for `??`, and
+// `JSON_CONTAINS_PATH(col, 'one', '$.key')` for MySQL.
+// Note: Like Embedded, col is not to be used for untrusted input.
+func JsonHasKey(col, key string) *Query {
+	return New("?", jsonHasKey{col: col, key: key})
+}
+
+// jsonPathString returns the MySQL style JSON path for keys, e.g. `$.a."b c"`.
+func jsonPathString(keys []string) string {
+	path := "$"
+	for _, k := range keys {
+		if isIdentifier(k) {
+			path += "." + k
+			continue
+		}
+		k = strings.ReplaceAll(k, `\`, `\\`)
+		path += `."` + strings.ReplaceAll(k, `"`, `\"`) + `"`
+	}
+	return path
+}
+
+func isIdentifier(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
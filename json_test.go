@@ -0,0 +1,162 @@
+package bqb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJsonPath(t *testing.T) {
+	q := New("SELECT * FROM users WHERE ? = ?", JsonPath("data", "address", "city"), "Paris")
+
+	sql, params, err := q.ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	want := "SELECT * FROM users WHERE data->$1->>$2 = $3"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{"address", "city", "Paris"}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	sql, params, _ = q.ToMysql()
+	want = "SELECT * FROM users WHERE JSON_EXTRACT(data, ?) = ?"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{"$.address.city", "Paris"}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	sql, _, _ = q.ToDialect(MSSQL)
+	want = "SELECT * FROM users WHERE JSON_VALUE(data, @p1) = @p2"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+
+	sql, _ = JsonPath("data", "a", "b c", `q"\`, "1x").ToRaw()
+	want = `JSON_EXTRACT(data, '$.a."b c"."q\"\\"."1x"')`
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+
+	sql, _, _ = JsonPath("data").ToPgsql()
+	if sql != "data" {
+		t.Errorf("got: %q, want: %q", sql, "data")
+	}
+
+	sql, _, _ = JsonPath("data", "a").ToPgsql()
+	if sql != "data->>$1" {
+		t.Errorf("got: %q, want: %q", sql, "data->>$1")
+	}
+}
+
+func TestJsonContains(t *testing.T) {
+	q := New("SELECT * FROM users WHERE ?", JsonContains("tags", JsonList{"admin"}))
+
+	sql, params, err := q.ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	want := "SELECT * FROM users WHERE tags @> $1::jsonb"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{`["admin"]`}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	sql, _ = q.ToRaw()
+	want = `SELECT * FROM users WHERE JSON_CONTAINS(tags, '["admin"]')`
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+
+	_, _, err = q.ToDialect(MSSQL)
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("expected unsupported error, got: %v", err)
+	}
+
+	_, _, err = JsonContains("tags", func() {}).ToPgsql()
+	if err == nil || !strings.Contains(err.Error(), "jsonify") {
+		t.Errorf("expected jsonify error, got: %v", err)
+	}
+}
+
+func TestJsonHasKey(t *testing.T) {
+	q := New("SELECT * FROM users WHERE ? AND id = ?", JsonHasKey("data", "email"), 1)
+
+	sql, params, err := q.ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	want := "SELECT * FROM users WHERE data ? $1 AND id = $2"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{"email", 1}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	sql, _, _ = q.ToMysql()
+	want = "SELECT * FROM users WHERE JSON_CONTAINS_PATH(data, 'one', ?) AND id = ?"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+
+	_, _, err = q.ToDialect(ORACLE)
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("expected unsupported error, got: %v", err)
+	}
+}
+
+func TestJsonValue(t *testing.T) {
+	type address struct {
+		City string `json:"city"`
+		Zip  *int   `json:"zip"`
+	}
+
+	q := New("INSERT INTO users (address, tags) VALUES (?, ?)", Json(address{City: "L'Aquila"}), Json([]string{"a"}))
+	sql, params, err := q.ToSql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	want := "INSERT INTO users (address, tags) VALUES (?, ?)"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{`{"city":"L'Aquila","zip":null}`, `["a"]`}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	sql, _ = q.ToRaw()
+	want = `INSERT INTO users (address, tags) VALUES ('{"city":"L''Aquila","zip":null}', '["a"]')`
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+
+	_, _, err = New("?", Json(func() {})).ToSql()
+	if err == nil || !strings.Contains(err.Error(), "jsonify") {
+		t.Errorf("expected jsonify error, got: %v", err)
+	}
+}
+
+func TestSetJsonMarshaler(t *testing.T) {
+	defer SetJsonMarshaler(nil)
+
+	SetJsonMarshaler(func(v any) ([]byte, error) {
+		return []byte(`"custom"`), nil
+	})
+	_, params, _ := New("? ? ?", Json(1), JsonMap{"a": 1}, &JsonList{1}).ToSql()
+	if !reflect.DeepEqual(params, []any{`"custom"`, `"custom"`, `"custom"`}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	SetJsonMarshaler(nil)
+	_, params, _ = New("?", Json(1)).ToSql()
+	if !reflect.DeepEqual(params, []any{"1"}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+}
@@ -0,0 +1,88 @@
+package bqb
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+const redactedText = "[REDACTED]"
+
+// Redacted wraps a parameter that is bound normally but is rendered as
+// [REDACTED] in logs and by ToRaw. See Secret.
+type Redacted struct {
+	value any
+}
+
+// Secret wraps v so that it binds normally but is never written to logs.
+func Secret(v any) Redacted {
+	return Redacted{value: v}
+}
+
+// LogValue implements slog.LogValuer.
+func (r Redacted) LogValue() slog.Value {
+	return slog.StringValue(redactedText)
+}
+
+// String implements fmt.Stringer.
+func (r Redacted) String() string {
+	return redactedText
+}
+
+// LogValue implements slog.LogValuer, logging the sql, redacted
+// parameters, and error of the Query.
+func (q *Query) LogValue() slog.Value {
+	sql, params, err := q.compile(SQL)
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Any("params", redactSecrets(params)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// PrintTo writes the sql, redacted parameters, and errors of a Query to w.
+func (q *Query) PrintTo(w io.Writer) {
+	sql, params, err := q.compile(SQL)
+	if PrintFormatted {
+		sql = formatSql(sql, "")
+	}
+	fmt.Fprintf(w, "SQL: %v\n", sql)
+	fmt.Fprintf(w, "PARAMS: %v\n", redactSecrets(params))
+	fmt.Fprintf(w, "ERROR: %v\n", err)
+}
+
+// redactSecrets returns a copy of params with Redacted values replaced.
+func redactSecrets(params []any) []any {
+	redacted := make([]any, len(params))
+	for i, p := range params {
+		if _, ok := p.(Redacted); ok {
+			p = redactedText
+		}
+		redacted[i] = p
+	}
+	return redacted
+}
+
+// secretParams wraps each value of params by Secret, unless it is already.
+func secretParams(params []any) []any {
+	for i, p := range params {
+		if _, ok := p.(Redacted); !ok {
+			params[i] = Secret(p)
+		}
+	}
+	return params
+}
+
+// unwrapSecrets replaces Redacted values in params with the values they
+// wrap so they can be bound.
+func unwrapSecrets(params []any) []any {
+	for i, p := range params {
+		if r, ok := p.(Redacted); ok {
+			params[i] = r.value
+		}
+	}
+	return params
+}
@@ -0,0 +1,75 @@
+package bqb
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSecret(t *testing.T) {
+	q := New("UPDATE users SET password = ? WHERE id = ? AND code IN (?)",
+		Secret("hunter2"), 1, Secret([]string{"a", "b"}))
+
+	sql, params, err := q.ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	want := "UPDATE users SET password = $1 WHERE id = $2 AND code IN ($3,$4)"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	wantParams := []any{"hunter2", 1, "a", "b"}
+	if !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("\n got: %v\nwant: %v", params, wantParams)
+	}
+
+	// Secrets of subqueries are bound but still redacted
+	sql, err = New("WITH u AS (?) SELECT 1", q).ToRaw()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	want = "WITH u AS (UPDATE users SET password = [REDACTED] WHERE id = 1 AND code IN ([REDACTED],[REDACTED])) SELECT 1"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+
+	if s := Secret("x").String(); s != "[REDACTED]" {
+		t.Errorf("got: %q", s)
+	}
+}
+
+func TestQuery_LogValue(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	q := New("SELECT * FROM users WHERE email = ? AND ssn = ?", "a@b.c", Secret("123-45-6789"))
+	logger.Info("query", "q", q)
+
+	out := buf.String()
+	if strings.Contains(out, "123-45-6789") {
+		t.Errorf("secret leaked in log: %v", out)
+	}
+	want := `q.sql="SELECT * FROM users WHERE email = ? AND ssn = ?" q.params="[a@b.c [REDACTED]]"`
+	if !strings.Contains(out, want) {
+		t.Errorf("\n got: %v\nwant: %v", out, want)
+	}
+
+	buf.Reset()
+	logger.Info("query", "q", New("?"), "s", Secret("x"))
+	out = buf.String()
+	if !strings.Contains(out, `q.error="extra ? in text`) || !strings.Contains(out, "s=[REDACTED]") {
+		t.Errorf("unexpected log output: %v", out)
+	}
+}
+
+func TestQuery_PrintTo(t *testing.T) {
+	var buf bytes.Buffer
+	New("SELECT * FROM users WHERE ssn = ?", Secret("123-45-6789")).PrintTo(&buf)
+
+	want := "SQL: SELECT * FROM users WHERE ssn = ?\nPARAMS: [[REDACTED]]\nERROR: <nil>\n"
+	if buf.String() != want {
+		t.Errorf("\n got: %q\nwant: %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,318 @@
+package bqb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type jsonQuery struct {
+	Parts          []QueryPart `json:"parts"`
+	OptionalPrefix string      `json:"optional_prefix,omitempty"`
+	OptionalSuffix string      `json:"optional_suffix,omitempty"`
+}
+
+type jsonQueryPart struct {
+	Text   string      `json:"text"`
+	Params []jsonParam `json:"params,omitempty"`
+	Errs   []string    `json:"errors,omitempty"`
+	Label  string      `json:"label,omitempty"`
+	Sep    string      `json:"sep,omitempty"`
+}
+
+// jsonParam is a parameter tagged with its type so it can be decoded
+// to the same Go type.
+type jsonParam struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// jsonFragment holds the fields of a fragment or guarded clause, which are
+// rendered when the decoded Query is compiled. Each type uses a subset.
+type jsonFragment struct {
+	Cols    []string           `json:"cols,omitempty"`
+	Keys    []string           `json:"keys,omitempty"`
+	Values  []jsonParam        `json:"values,omitempty"`
+	Value   json.RawMessage    `json:"value,omitempty"`
+	Limit   int                `json:"limit,omitempty"`
+	Offset  int                `json:"offset,omitempty"`
+	Queries map[Dialect]*Query `json:"queries,omitempty"`
+	Query   *Query             `json:"query,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, preserving the parts and optional
+// prefix and suffix of the Query.
+// Note: Hooks, comments, policies and the subqueries visited by Walk are not
+// included.
+func (q *Query) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonQuery{
+		Parts:          q.Parts,
+		OptionalPrefix: q.OptionalPrefix,
+		OptionalSuffix: q.OptionalSuffix,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (q *Query) UnmarshalJSON(data []byte) error {
+	var jq jsonQuery
+	if err := json.Unmarshal(data, &jq); err != nil {
+		return err
+	}
+	q.Parts = jq.Parts
+	q.OptionalPrefix = jq.OptionalPrefix
+	q.OptionalSuffix = jq.OptionalSuffix
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. Params are tagged with their type
+// so that they bind identically once decoded. Supported params are nil,
+// bools, strings, numeric kinds, time.Time, []byte, *int, *string, Folded,
+// Redacted values wrapping those types, guarded clauses and the arguments
+// added by Paginate, After, Returning, Upsert, ByDialect and the Json
+// operators, which are rendered for the dialect the decoded Query is
+// compiled for.
+func (p QueryPart) MarshalJSON() ([]byte, error) {
+	jp := jsonQueryPart{Text: p.Text, Label: p.Label, Sep: p.sep}
+	for _, param := range p.Params {
+		encoded, err := encodeParam(param)
+		if err != nil {
+			return nil, err
+		}
+		jp.Params = append(jp.Params, encoded)
+	}
+	for _, err := range p.Errs {
+		jp.Errs = append(jp.Errs, err.Error())
+	}
+	return json.Marshal(jp)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *QueryPart) UnmarshalJSON(data []byte) error {
+	var jp jsonQueryPart
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return err
+	}
+
+	part := QueryPart{Text: jp.Text, Label: jp.Label, sep: jp.Sep, Errs: []error{}}
+	for _, encoded := range jp.Params {
+		param, err := decodeParam(encoded)
+		if err != nil {
+			return err
+		}
+		part.Params = append(part.Params, param)
+	}
+	for _, msg := range jp.Errs {
+		part.Errs = append(part.Errs, errors.New(msg))
+	}
+
+	*p = part
+	return nil
+}
+
+func encodeParam(param any) (jsonParam, error) {
+	var typ string
+	switch v := param.(type) {
+	case nil:
+		return jsonParam{Type: "null"}, nil
+	case *int:
+		if v == nil {
+			return jsonParam{Type: "null"}, nil
+		}
+		return encodeParam(*v)
+	case *string:
+		if v == nil {
+			return jsonParam{Type: "null"}, nil
+		}
+		return encodeParam(*v)
+	case Redacted:
+		return encodeNested("secret", v.value)
+	case Folded:
+		return encodeNested("folded", []any(v)...)
+	case bool, string, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, float32, float64:
+		typ = fmt.Sprintf("%T", v)
+	case time.Time:
+		typ = "time"
+	case []byte:
+		typ = "bytes"
+	case pagination:
+		return encodeFragment("paginate", jsonFragment{Limit: v.limit, Offset: v.offset})
+	case keyset:
+		values := make([]jsonParam, len(v.values))
+		for i, value := range v.values {
+			encoded, err := encodeParam(value)
+			if err != nil {
+				return jsonParam{}, err
+			}
+			values[i] = encoded
+		}
+		return encodeFragment("keyset", jsonFragment{Cols: v.cols, Values: values})
+	case returning:
+		return encodeFragment("returning", jsonFragment{Cols: v.cols})
+	case upsertClause:
+		return encodeFragment("upsert", jsonFragment{Cols: v.conflictCols, Keys: v.updateCols})
+	case jsonPath:
+		return encodeFragment("json_path", jsonFragment{Cols: []string{v.col}, Keys: v.keys})
+	case jsonContains:
+		value, err := jsonMarshal(v.value)
+		if err != nil {
+			return jsonParam{}, fmt.Errorf("cannot marshal JsonContains value: %v", err)
+		}
+		return encodeFragment("json_contains", jsonFragment{Cols: []string{v.col}, Value: value})
+	case jsonHasKey:
+		return encodeFragment("json_has_key", jsonFragment{Cols: []string{v.col}, Keys: []string{v.key}})
+	case ByDialect:
+		return encodeFragment("by_dialect", jsonFragment{Queries: v})
+	case guard:
+		return encodeFragment("guard", jsonFragment{Cols: v.query.guards, Query: v.query})
+	default:
+		return jsonParam{}, fmt.Errorf("cannot marshal param of type %T", param)
+	}
+
+	value, err := json.Marshal(param)
+	if err != nil {
+		return jsonParam{}, err
+	}
+	return jsonParam{Type: typ, Value: value}, nil
+}
+
+func encodeNested(typ string, params ...any) (jsonParam, error) {
+	nested := make([]jsonParam, len(params))
+	for i, p := range params {
+		encoded, err := encodeParam(p)
+		if err != nil {
+			return jsonParam{}, err
+		}
+		nested[i] = encoded
+	}
+
+	var value []byte
+	var err error
+	if typ == "secret" {
+		value, err = json.Marshal(nested[0])
+	} else {
+		value, err = json.Marshal(nested)
+	}
+	return jsonParam{Type: typ, Value: value}, err
+}
+
+func encodeFragment(typ string, f jsonFragment) (jsonParam, error) {
+	value, err := json.Marshal(f)
+	return jsonParam{Type: typ, Value: value}, err
+}
+
+func decodeParam(encoded jsonParam) (any, error) {
+	switch encoded.Type {
+	case "null":
+		return nil, nil
+	case "bool":
+		return decodeValue[bool](encoded.Value)
+	case "string":
+		return decodeValue[string](encoded.Value)
+	case "int":
+		return decodeValue[int](encoded.Value)
+	case "int8":
+		return decodeValue[int8](encoded.Value)
+	case "int16":
+		return decodeValue[int16](encoded.Value)
+	case "int32":
+		return decodeValue[int32](encoded.Value)
+	case "int64":
+		return decodeValue[int64](encoded.Value)
+	case "uint":
+		return decodeValue[uint](encoded.Value)
+	case "uint8":
+		return decodeValue[uint8](encoded.Value)
+	case "uint16":
+		return decodeValue[uint16](encoded.Value)
+	case "uint32":
+		return decodeValue[uint32](encoded.Value)
+	case "uint64":
+		return decodeValue[uint64](encoded.Value)
+	case "float32":
+		return decodeValue[float32](encoded.Value)
+	case "float64":
+		return decodeValue[float64](encoded.Value)
+	case "time":
+		return decodeValue[time.Time](encoded.Value)
+	case "bytes":
+		return decodeValue[[]byte](encoded.Value)
+	case "secret":
+		nested, err := decodeValue[jsonParam](encoded.Value)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeParam(nested)
+		return Secret(value), err
+	case "folded":
+		nested, err := decodeValue[[]jsonParam](encoded.Value)
+		if err != nil {
+			return nil, err
+		}
+		folded := make(Folded, len(nested))
+		for i, n := range nested {
+			if folded[i], err = decodeParam(n); err != nil {
+				return nil, err
+			}
+		}
+		return folded, nil
+	case "paginate", "keyset", "returning", "upsert", "json_path", "json_contains", "json_has_key",
+		"by_dialect", "guard":
+		f, err := decodeValue[jsonFragment](encoded.Value)
+		if err != nil {
+			return nil, err
+		}
+		return decodeFragment(encoded.Type, f)
+	default:
+		return nil, fmt.Errorf("cannot unmarshal param of type %q", encoded.Type)
+	}
+}
+
+func decodeFragment(typ string, f jsonFragment) (any, error) {
+	col := ""
+	if len(f.Cols) > 0 {
+		col = f.Cols[0]
+	}
+
+	switch typ {
+	case "paginate":
+		return pagination{limit: f.Limit, offset: f.Offset}, nil
+	case "keyset":
+		values := make([]any, len(f.Values))
+		for i, v := range f.Values {
+			value, err := decodeParam(v)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return keyset{cols: f.Cols, values: values}, nil
+	case "returning":
+		return returning{cols: f.Cols}, nil
+	case "upsert":
+		return upsertClause{conflictCols: f.Cols, updateCols: f.Keys}, nil
+	case "json_path":
+		return jsonPath{col: col, keys: f.Keys}, nil
+	case "json_contains":
+		return jsonContains{col: col, value: f.Value}, nil
+	case "json_has_key":
+		if len(f.Keys) != 1 {
+			return nil, errors.New("cannot unmarshal json_has_key without a key")
+		}
+		return jsonHasKey{col: col, key: f.Keys[0]}, nil
+	case "by_dialect":
+		return ByDialect(f.Queries), nil
+	default:
+		if f.Query == nil {
+			return nil, errors.New("cannot unmarshal guard without a query")
+		}
+		return guard{query: f.Query.Guard(f.Cols...)}, nil
+	}
+}
+
+func decodeValue[T any](data json.RawMessage) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
@@ -0,0 +1,171 @@
+package bqb
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuery_MarshalJSON(t *testing.T) {
+	name := "ed"
+	var nilName *string
+	created := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+
+	where := Optional("WHERE").
+		And("id IN (?)", []int{1, 2}).
+		And("name = ? AND alias = ?", &name, nilName).
+		And("created > ? AND data = ?", created, []byte("x")).
+		And("n = ? AND f = ? AND u = ? AND b = ?", int64(1<<62+1), float32(1.5), uint8(3), true).
+		And("secret = ? AND tags = ?", Secret("pw"), Folded{"a", 1})
+	q := New("SELECT * FROM users ?", where)
+
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	var decoded Query
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+
+	wantSql, wantParams, _ := q.ToPgsql()
+	sql, params, err := decoded.ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	if sql != wantSql {
+		t.Errorf("\n got: %q\nwant: %q", sql, wantSql)
+	}
+
+	// Pointers are decoded as the values they point to
+	wantParams[2], wantParams[3] = "ed", nil
+	if !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("\n got: %#v\nwant: %#v", params, wantParams)
+	}
+
+	var buf strings.Builder
+	decoded.PrintTo(&buf)
+	if !strings.Contains(buf.String(), "[REDACTED]") || strings.Contains(buf.String(), "pw") {
+		t.Errorf("secret not preserved: %v", buf.String())
+	}
+}
+
+func TestQuery_MarshalJSON_Optional(t *testing.T) {
+	q := OptionalWrap("(", ")")
+	data, _ := json.Marshal(q)
+	want := `{"parts":null,"optional_prefix":"(","optional_suffix":")"}`
+	if string(data) != want {
+		t.Errorf("\n got: %v\nwant: %v", string(data), want)
+	}
+
+	decoded := &Query{}
+	_ = json.Unmarshal(data, decoded)
+	decoded.Space("a")
+	sql, _ := decoded.ToRaw()
+	if sql != "(a)" {
+		t.Errorf("got: %q, want: %q", sql, "(a)")
+	}
+
+	data, _ = json.Marshal(New("?"))
+	decoded = &Query{}
+	_ = json.Unmarshal(data, decoded)
+	_, _, err := decoded.ToSql()
+	if err == nil || !strings.Contains(err.Error(), "extra ?") {
+		t.Errorf("expected error to be preserved, got: %v", err)
+	}
+
+	data, _ = json.Marshal(New("LIMIT 10").Label("limit"))
+	want = `{"parts":[{"text":"LIMIT 10","label":"limit"}]}`
+	if string(data) != want {
+		t.Errorf("\n got: %v\nwant: %v", string(data), want)
+	}
+	decoded = &Query{}
+	_ = json.Unmarshal(data, decoded)
+	if decoded.Find("limit") == nil {
+		t.Error("expected label to be preserved")
+	}
+
+	data, _ = json.Marshal(New("a = 1").Label("a").And("b = 2"))
+	decoded = &Query{}
+	_ = json.Unmarshal(data, decoded)
+	sql, _ = decoded.Remove("a").ToRaw()
+	if sql != "b = 2" {
+		t.Errorf("expected separator to be preserved, got: %q", sql)
+	}
+}
+
+func TestQuery_MarshalJSON_Fragments(t *testing.T) {
+	defer ClearPolicies()
+	AddPolicy(Policy{Table: "orders", Predicate: New("tenant_id = ?", 7)})
+
+	where := Optional("WHERE").Guard("orders").
+		And("?", After([]string{"created", "id"}, []any{"2024-01-01", 10})).
+		And("?", JsonHasKey("data", "k")).
+		And("? = ?", JsonPath("data", "a", "b"), "x").
+		And("?", JsonContains("data", map[string]any{"a": 1})).
+		And("created < ?", Secret(ByDialect{PGSQL: New("NOW()"), SQL: New("CURRENT_TIMESTAMP")}))
+	queries := []*Query{
+		New("SELECT * FROM orders ?", where).Paginate(10, 20),
+		Upsert("users", []string{"id", "name"}, []string{"id"}, []string{"name"}, 1, "ed").Returning("id"),
+	}
+
+	for _, q := range queries {
+		data, err := json.Marshal(q)
+		if err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+		var decoded Query
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+
+		for _, dialect := range []Dialect{PGSQL, MARIADB, MSSQL} {
+			wantSql, wantParams, wantErr := q.ToDialect(dialect)
+			sql, params, err := decoded.ToDialect(dialect)
+			if sql != wantSql || fmt.Sprint(err) != fmt.Sprint(wantErr) {
+				t.Errorf("%v:\n got: %q %v\nwant: %q %v", dialect, sql, err, wantSql, wantErr)
+			}
+			if !reflect.DeepEqual(params, wantParams) {
+				t.Errorf("%v: got params %v, want %v", dialect, params, wantParams)
+			}
+		}
+	}
+}
+
+func TestQuery_MarshalJSON_Errors(t *testing.T) {
+	for _, q := range []*Query{
+		New("?", struct{}{}),
+		New("?", Secret(struct{}{})),
+		New("?", Folded{struct{}{}}),
+		New("?", After([]string{"id"}, []any{struct{}{}})),
+		New("?", JsonContains("c", make(chan int))),
+	} {
+		if _, err := json.Marshal(q); err == nil || !strings.Contains(err.Error(), "cannot marshal") {
+			t.Errorf("expected marshal error, got: %v", err)
+		}
+	}
+
+	for _, data := range []string{
+		`{"parts":[{"text":"?","params":[{"type":"complex"}]}]}`,
+		`{"parts":[{"text":"?","params":[{"type":"int","value":"x"}]}]}`,
+		`{"parts":[{"text":"?","params":[{"type":"secret","value":1}]}]}`,
+		`{"parts":[{"text":"?","params":[{"type":"secret","value":{"type":"x"}}]}]}`,
+		`{"parts":[{"text":"?","params":[{"type":"folded","value":1}]}]}`,
+		`{"parts":[{"text":"?","params":[{"type":"folded","value":[{"type":"x"}]}]}]}`,
+		`{"parts":[{"text":"?","params":[{"type":"keyset","value":{"values":[{"type":"x"}]}}]}]}`,
+		`{"parts":[{"text":"?","params":[{"type":"paginate","value":1}]}]}`,
+		`{"parts":[{"text":"?","params":[{"type":"json_has_key","value":{}}]}]}`,
+		`{"parts":[{"text":"?","params":[{"type":"guard","value":{}}]}]}`,
+		`{"parts":1}`,
+		`[]`,
+	} {
+		var q Query
+		if err := json.Unmarshal([]byte(data), &q); err == nil {
+			t.Errorf("expected unmarshal error for %v", data)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package bqb
+
+import (
+	"fmt"
+	"strings"
+)
+
+type pagination struct {
+	limit  int
+	offset int
+}
+
+func (p pagination) render(dialect Dialect) (*Query, error) {
+	switch dialect {
+	case MSSQL, ORACLE:
+		return New("OFFSET ? ROWS FETCH NEXT ? ROWS ONLY", p.offset, p.limit), nil
+	default:
+		return New("LIMIT ? OFFSET ?", p.limit, p.offset), nil
+	}
+}
+
+// PaginateLabel is the label of the QueryPart added by Paginate.
+const PaginateLabel = "paginate"
+
+// Paginate adds a LIMIT and OFFSET clause to the Query, which is rendered
+// as `OFFSET ? ROWS FETCH NEXT ? ROWS ONLY` for SQL Server and Oracle.
+// The clause is labelled with PaginateLabel.
+func (q *Query) Paginate(limit, offset int) *Query {
+	return q.Space("?", pagination{limit: limit, offset: offset}).Label(PaginateLabel)
+}
+
+type keyset struct {
+	cols   []string
+	values []any
+}
+
+func (k keyset) render(dialect Dialect) (*Query, error) {
+	if len(k.cols) == 0 || len(k.cols) != len(k.values) {
+		return nil, fmt.Errorf("keyset needs one value per column: %v (%d values)", k.cols, len(k.values))
+	}
+	if len(k.cols) == 1 {
+		return New(k.cols[0]+" > ?", k.values[0]), nil
+	}
+
+	switch dialect {
+	case MSSQL, ORACLE:
+		// Row values are not supported, so expand to
+		// (a > ? OR (a = ? AND b > ?) OR ...)
+		or := Q()
+		for i := range k.cols {
+			and := Q()
+			for j := 0; j < i; j++ {
+				and.And(k.cols[j]+" = ?", k.values[j])
+			}
+			and.And(k.cols[i]+" > ?", k.values[i])
+			if i == 0 {
+				or.Or("?", and)
+			} else {
+				or.Or("(?)", and)
+			}
+		}
+		return New("(?)", or), nil
+	default:
+		phs := strings.TrimSuffix(strings.Repeat("?,", len(k.values)), ",")
+		return New("("+strings.Join(k.cols, ",")+") > ("+phs+")", k.values...), nil
+	}
+}
+
+// After returns a keyset pagination condition matching rows that sort
+// after lastValues on cols, e.g. `(created,id) > (?,?)`. Dialects without
+// row value comparisons get the equivalent expanded OR conditions.
+// Note: Like Embedded, cols are not to be used for untrusted input.
+func After(cols []string, lastValues []any) *Query {
+	return New("?", keyset{cols: cols, values: lastValues})
+}
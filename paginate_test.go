@@ -0,0 +1,90 @@
+package bqb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuery_Paginate(t *testing.T) {
+	q := New("SELECT * FROM users WHERE age > ?", 21).Space("ORDER BY id").Paginate(10, 20)
+
+	tests := []struct {
+		dialect Dialect
+		want    string
+		params  []any
+	}{
+		{PGSQL, "SELECT * FROM users WHERE age > $1 ORDER BY id LIMIT $2 OFFSET $3", []any{21, 10, 20}},
+		{MYSQL, "SELECT * FROM users WHERE age > ? ORDER BY id LIMIT ? OFFSET ?", []any{21, 10, 20}},
+		{SQL, "SELECT * FROM users WHERE age > ? ORDER BY id LIMIT ? OFFSET ?", []any{21, 10, 20}},
+		{MSSQL, "SELECT * FROM users WHERE age > @p1 ORDER BY id OFFSET @p2 ROWS FETCH NEXT @p3 ROWS ONLY", []any{21, 20, 10}},
+		{ORACLE, "SELECT * FROM users WHERE age > :1 ORDER BY id OFFSET :2 ROWS FETCH NEXT :3 ROWS ONLY", []any{21, 20, 10}},
+		{RAW, "SELECT * FROM users WHERE age > 21 ORDER BY id LIMIT 10 OFFSET 20", nil},
+	}
+
+	for _, tt := range tests {
+		sql, params, err := q.ToDialect(tt.dialect)
+		if err != nil {
+			t.Errorf("%v: got error: %v", tt.dialect, err)
+		}
+		if sql != tt.want {
+			t.Errorf("%v:\n got: %q\nwant: %q", tt.dialect, sql, tt.want)
+		}
+		if !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%v: got params: %v, want: %v", tt.dialect, params, tt.params)
+		}
+	}
+
+	// Fragments of subqueries are rendered with the outer dialect
+	sql, _, _ := New("SELECT * FROM (?) AS sub", q).ToDialect(MSSQL)
+	want := "SELECT * FROM (SELECT * FROM users WHERE age > @p1 ORDER BY id OFFSET @p2 ROWS FETCH NEXT @p3 ROWS ONLY) AS sub"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+}
+
+func TestAfter(t *testing.T) {
+	where := Optional("WHERE").And("active").And("?", After([]string{"created", "id"}, []any{"2024-01-01", 7}))
+
+	sql, params, err := where.ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	want := "WHERE active AND (created,id) > ($1,$2)"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{"2024-01-01", 7}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	sql, params, _ = where.ToDialect(MSSQL)
+	want = "WHERE active AND (created > @p1 OR (created = @p2 AND id > @p3))"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{"2024-01-01", "2024-01-01", 7}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	sql, _, _ = After([]string{"a", "b", "c"}, []any{1, 2, 3}).ToDialect(ORACLE)
+	want = "(a > :1 OR (a = :2 AND b > :3) OR (a = :4 AND b = :5 AND c > :6))"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+
+	sql, _ = After([]string{"id"}, []any{7}).ToRaw()
+	if sql != "id > 7" {
+		t.Errorf("got: %q, want: %q", sql, "id > 7")
+	}
+
+	_, _, err = After([]string{"a", "b"}, []any{1}).ToSql()
+	if err == nil || !strings.Contains(err.Error(), "keyset") {
+		t.Errorf("expected keyset error, got: %v", err)
+	}
+
+	_, _, err = New("SELECT (?)", After(nil, nil)).ToSql()
+	if err == nil {
+		t.Errorf("expected keyset error for nested fragment")
+	}
+}
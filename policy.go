@@ -0,0 +1,409 @@
+package bqb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Policy requires a filter on every query touching Table, such as a tenant
+// or soft delete predicate. Policies are applied when a Query is compiled to
+// the clauses marked with Guard.
+//
+// A clause which already contains a part labelled Label satisfies the
+// Policy. Otherwise Predicate is injected into the clause with AND, and when
+// Predicate is nil compilation fails.
+type Policy struct {
+	Table     string
+	Predicate *Query
+	Label     string
+}
+
+// guard is the param standing in for a clause marked with Guard until the
+// policies are applied.
+type guard struct {
+	query *Query
+}
+
+var (
+	policiesMu     sync.RWMutex
+	globalPolicies []Policy
+	guardMarkRe    = regexp.MustCompile("\x00(S|E)(\\d+)\x00|" + regexp.QuoteMeta(paramPh))
+	unquoter       = strings.NewReplacer(`"`, "", "`", "", "[", "", "]", "")
+)
+
+// AddPolicy registers a Policy that is applied to every Query.
+func AddPolicy(p Policy) {
+	policiesMu.Lock()
+	defer policiesMu.Unlock()
+	globalPolicies = append(globalPolicies, p)
+}
+
+// ClearPolicies removes all policies registered with AddPolicy.
+func ClearPolicies() {
+	policiesMu.Lock()
+	defer policiesMu.Unlock()
+	globalPolicies = nil
+}
+
+// WithPolicy registers a Policy that is applied only when this Query is
+// compiled, after any global policies.
+// Note: Like hooks, policies are only applied by the outermost Query.
+func (q *Query) WithPolicy(p Policy) *Query {
+	if q == nil {
+		q = Q()
+	}
+	q.policies = append(q.policies, p)
+	return q
+}
+
+// Guard marks the Query, usually an Optional("WHERE") clause, as the place
+// where policies for tables inject their predicates. With no tables the
+// clause is guarded for every policy.
+//
+// Tables are referenced by naming them after FROM, including comma separated
+// lists, JOIN, UPDATE or INTO. The target of INSERT INTO or REPLACE INTO,
+// such as the table of Upsert, is not a reference, since there are no rows
+// to filter. A compiled statement which references a table
+// with a policy fails unless it has a guarded clause of its own, where
+// statements are separated by parentheses, UNION, INTERSECT, EXCEPT and
+// semicolons. A clause guarded for a table its statement does not reference also
+// fails.
+// Note: A guarded Query is rendered when the outermost Query is compiled,
+// so it may still be changed after it has been passed as an argument.
+func (q *Query) Guard(tables ...string) *Query {
+	if q == nil {
+		q = Q()
+	}
+	q.guarded = true
+	q.guards = append(q.guards, tables...)
+	return q
+}
+
+// applyPolicies renders each guarded clause in params, injecting the
+// predicates of the policies, and checks that every statement of sql which
+// references a guarded table has a guarded clause.
+func (q *Query) applyPolicies(dialect Dialect, sql string, params []any) (string, []any, error) {
+	policiesMu.RLock()
+	policies := append([]Policy{}, globalPolicies...)
+	policiesMu.RUnlock()
+	policies = append(policies, q.policies...)
+
+	if len(policies) == 0 && !hasGuard(params) {
+		return sql, params, nil
+	}
+
+	s := &policyState{dialect: dialect, policies: policies}
+	sql, params, err := s.mark(sql, params)
+	if err != nil {
+		return "", nil, err
+	}
+
+	scopes := scanScopes(guardMarkRe.ReplaceAllStringFunc(sql, func(m string) string {
+		if strings.HasPrefix(m, "\x00S") {
+			return " \x00" + strings.Trim(m, "\x00") + " "
+		}
+		return " "
+	}))
+
+	// covered holds the tables of each scope with a guarded clause.
+	covered := map[int]map[string]bool{}
+	predicates := make([][]*Query, len(s.guards))
+	for i, g := range s.guards {
+		scope := scopes.guards[i]
+		if covered[scope] == nil {
+			covered[scope] = map[string]bool{}
+		}
+		for _, p := range policies {
+			table := strings.ToLower(p.Table)
+			if !g.guards.covers(table) {
+				continue
+			}
+			if !scopes.references(scope, table) {
+				if len(g.guards) > 0 {
+					return "", nil, fmt.Errorf("clause guarded for %v is not in a statement on %v", p.Table, p.Table)
+				}
+				continue
+			}
+			covered[scope][table] = true
+
+			if p.Label != "" && g.Find(p.Label) != nil {
+				continue
+			}
+			if p.Predicate == nil {
+				return "", nil, fmt.Errorf("policy on %v requires %v in %v", p.Table, p.Label, g.OptionalPrefix)
+			}
+			predicates[i] = append(predicates[i], p.Predicate)
+		}
+	}
+
+	for scope := range scopes.tables {
+		for _, p := range policies {
+			table := strings.ToLower(p.Table)
+			if scopes.references(scope, table) && !covered[scope][table] {
+				return "", nil, fmt.Errorf("query on guarded table %v has no clause for its policy", p.Table)
+			}
+		}
+	}
+
+	return s.build(sql, params, predicates)
+}
+
+type policyState struct {
+	dialect  Dialect
+	policies []Policy
+	guards   []*Query
+}
+
+// mark replaces the guard params in params with the text of their clauses,
+// without the prefix and suffix, between markers holding the index of the
+// guard in s.guards.
+func (s *policyState) mark(sql string, params []any) (string, []any, error) {
+	if !hasGuard(params) {
+		return sql, params, nil
+	}
+
+	parts := strings.Split(sql, paramPh)
+	var builder strings.Builder
+	var newParams []any
+	for i, p := range params {
+		builder.WriteString(parts[i])
+
+		g, secret, ok := asGuard(p)
+		if !ok {
+			builder.WriteString(paramPh)
+			newParams = append(newParams, p)
+			continue
+		}
+
+		gsql, gparams, err := (&Query{Parts: g.query.Parts}).toSql()
+		if err == nil {
+			gsql, gparams, err = resolveFragments(s.dialect, gsql, gparams)
+		}
+		if err == nil {
+			gsql, gparams, err = s.mark(gsql, gparams)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if secret {
+			gparams = secretParams(gparams)
+		}
+
+		n := strconv.Itoa(len(s.guards))
+		s.guards = append(s.guards, g.query)
+		builder.WriteString("\x00S" + n + "\x00" + gsql + "\x00E" + n + "\x00")
+		newParams = append(newParams, gparams...)
+	}
+	builder.WriteString(parts[len(parts)-1])
+
+	return builder.String(), newParams, nil
+}
+
+// build replaces each marked clause of sql with its prefix and suffix,
+// joining the clause and its predicates with AND.
+func (s *policyState) build(sql string, params []any, predicates [][]*Query) (string, []any, error) {
+	type frame struct {
+		guard   int
+		builder strings.Builder
+		params  []any
+	}
+	stack := []*frame{{guard: -1}}
+	next, last := 0, 0
+
+	for _, m := range guardMarkRe.FindAllStringSubmatchIndex(sql, -1) {
+		top := stack[len(stack)-1]
+		top.builder.WriteString(sql[last:m[0]])
+		last = m[1]
+
+		switch {
+		case m[2] < 0:
+			top.builder.WriteString(paramPh)
+			top.params = append(top.params, params[next])
+			next++
+		case sql[m[2]] == 'S':
+			n, _ := strconv.Atoi(sql[m[4]:m[5]])
+			stack = append(stack, &frame{guard: n})
+		default:
+			stack = stack[:len(stack)-1]
+			parent := stack[len(stack)-1]
+			csql, cparams, err := s.clause(top.guard, top.builder.String(), top.params, predicates[top.guard])
+			if err != nil {
+				return "", nil, err
+			}
+			parent.builder.WriteString(csql)
+			parent.params = append(parent.params, cparams...)
+		}
+	}
+	stack[0].builder.WriteString(sql[last:])
+	return stack[0].builder.String(), stack[0].params, nil
+}
+
+// clause renders the guarded clause i with the text and params of its parts
+// and predicates. When there are predicates, the text and each predicate are
+// wrapped in parentheses so that they apply to the whole condition.
+func (s *policyState) clause(i int, sql string, params []any, predicates []*Query) (string, []any, error) {
+	g := s.guards[i]
+	clause := &Query{OptionalPrefix: g.OptionalPrefix, OptionalSuffix: g.OptionalSuffix}
+	if sql = strings.TrimSpace(sql); sql != "" {
+		if len(predicates) > 0 {
+			sql = "(" + sql + ")"
+		}
+		clause.Parts = append(clause.Parts, QueryPart{Text: sql, Params: params})
+	}
+	for _, p := range predicates {
+		psql, pparams, err := p.toSql()
+		if err == nil {
+			psql, pparams, err = resolveFragments(s.dialect, psql, pparams)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		psql = "(" + psql + ")"
+		if len(clause.Parts) > 0 {
+			psql = " AND " + psql
+		}
+		clause.Parts = append(clause.Parts, QueryPart{Text: psql, Params: pparams})
+	}
+	return clause.toSql()
+}
+
+func hasGuard(params []any) bool {
+	for _, p := range params {
+		if _, _, ok := asGuard(p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// asGuard returns p as a guard, unwrapping a guard wrapped by Secret, and
+// whether it was wrapped.
+func asGuard(p any) (guard, bool, bool) {
+	if r, ok := p.(Redacted); ok {
+		g, ok := r.value.(guard)
+		return g, true, ok
+	}
+	g, ok := p.(guard)
+	return g, false, ok
+}
+
+// guardTables are the tables a clause is guarded for, or every table when
+// empty.
+type guardTables []string
+
+func (g guardTables) covers(table string) bool {
+	if len(g) == 0 {
+		return true
+	}
+	for _, t := range g {
+		if strings.EqualFold(t, table) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyScopes holds the tables referenced by each statement scope of a
+// query, and the scope of each guarded clause.
+type policyScopes struct {
+	tables [][]string
+	guards map[int]int
+}
+
+// references reports whether scope references table, by name or as the last
+// part of a qualified name.
+func (p *policyScopes) references(scope int, table string) bool {
+	for _, name := range p.tables[scope] {
+		if name == table || strings.HasSuffix(name, "."+table) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanScopes splits sql into statement scopes at parentheses, UNION,
+// INTERSECT, EXCEPT and semicolons, collecting the tables each references
+// and the scope of each guard marker.
+func scanScopes(sql string) *policyScopes {
+	type state struct {
+		scope  int
+		inFrom bool
+		expect bool
+	}
+	scopes := &policyScopes{tables: [][]string{nil}, guards: map[int]int{}}
+	newScope := func() int {
+		scopes.tables = append(scopes.tables, nil)
+		return len(scopes.tables) - 1
+	}
+	stack := []*state{{}}
+
+	tokens := tokenizeSql(sql)
+	for i := 0; i < len(tokens); i++ {
+		top := stack[len(stack)-1]
+		text := tokens[i].text
+		upper := strings.ToUpper(text)
+
+		switch {
+		case text == "(":
+			top.expect = false
+			stack = append(stack, &state{scope: newScope()})
+		case text == ")":
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case text == ";" || upper == "UNION" || upper == "INTERSECT" || upper == "EXCEPT":
+			*top = state{scope: newScope()}
+		case text == "\x00" && i+1 < len(tokens):
+			n, _ := strconv.Atoi(strings.TrimPrefix(tokens[i+1].text, "S"))
+			scopes.guards[n] = top.scope
+			top.inFrom, top.expect = false, false
+			i++
+		case upper == "FROM":
+			top.inFrom, top.expect = true, true
+		case upper == "INTO" && insertsInto(tokens, i):
+			// The target of an INSERT is not filtered by policies.
+		case upper == "UPDATE" && i > 0 && (strings.EqualFold(tokens[i-1].text, "DO") || strings.EqualFold(tokens[i-1].text, "KEY")):
+			// ON CONFLICT ... DO UPDATE and ON DUPLICATE KEY UPDATE update
+			// the target of the INSERT.
+		case upper == "JOIN" || upper == "UPDATE" || upper == "INTO":
+			top.expect = true
+		case text == ",":
+			top.expect = top.inFrom
+		case fromEndKeywords[upper]:
+			top.inFrom, top.expect = false, false
+		case top.expect && upper != "ONLY" && upper != "LATERAL":
+			name := text
+			for i+1 < len(tokens) && !tokens[i+1].spaceBefore && !strings.Contains("(),;", tokens[i+1].text) {
+				i++
+				name += tokens[i].text
+			}
+			name = strings.ToLower(unquoter.Replace(name))
+			scopes.tables[top.scope] = append(scopes.tables[top.scope], name)
+			top.expect = false
+		}
+	}
+	return scopes
+}
+
+// insertsInto reports whether the INTO at tokens[i] follows INSERT or
+// REPLACE, optionally with IGNORE between them.
+func insertsInto(tokens []sqlToken, i int) bool {
+	if i > 0 && strings.EqualFold(tokens[i-1].text, "IGNORE") {
+		i--
+	}
+	if i == 0 {
+		return false
+	}
+	prev := strings.ToUpper(tokens[i-1].text)
+	return prev == "INSERT" || prev == "REPLACE"
+}
+
+// fromEndKeywords end the list of tables after FROM.
+var fromEndKeywords = map[string]bool{
+	"WHERE": true, "GROUP": true, "ORDER": true, "HAVING": true, "LIMIT": true,
+	"OFFSET": true, "FETCH": true, "WINDOW": true, "RETURNING": true,
+	"SET": true, "VALUES": true, "SELECT": true, "FOR": true,
+}
@@ -0,0 +1,215 @@
+package bqb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPolicy_Inject(t *testing.T) {
+	tenant := Policy{Table: "orders", Predicate: New("tenant_id = ?", 7)}
+
+	where := Optional("WHERE").Guard("orders")
+	q := New("SELECT * FROM orders ?", where).Space("LIMIT ?", 10).WithPolicy(tenant)
+
+	// clauses are rendered at compile time, so later changes apply
+	where.And("status = ?", "open").Or("status = ?", "new")
+
+	sql, params, err := q.ToPgsql()
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	want := "SELECT * FROM orders WHERE (status = $1 OR status = $2) AND (tenant_id = $3) LIMIT $4"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{"open", "new", 7, 10}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	empty := New("SELECT * FROM public.orders ?", Optional("WHERE").Guard()).WithPolicy(tenant)
+	sql, params, _ = empty.ToSql()
+	if sql != "SELECT * FROM public.orders WHERE (tenant_id = ?)" || !reflect.DeepEqual(params, []any{7}) {
+		t.Errorf("unexpected sql: %q %v", sql, params)
+	}
+
+	// without policies a guarded clause renders as usual
+	sql, _, _ = New("SELECT * FROM orders ?", where).ToSql()
+	if sql != "SELECT * FROM orders WHERE status = ? OR status = ?" {
+		t.Errorf("unexpected sql: %q", sql)
+	}
+}
+
+func TestPolicy_Nested(t *testing.T) {
+	AddPolicy(Policy{Table: "orders", Predicate: New("o.tenant_id = ?", 1)})
+	defer ClearPolicies()
+
+	sub := New("SELECT user_id FROM orders o ?", Optional("WHERE").Guard("orders"))
+	where := Optional("WHERE").Guard("orders").And("id IN (?)", sub)
+	q := New("SELECT * FROM users u JOIN orders o ON o.user_id = u.id ?", where)
+
+	sql, params, err := q.ToSql()
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	want := "SELECT * FROM users u JOIN orders o ON o.user_id = u.id WHERE (id IN (SELECT user_id FROM orders o WHERE (o.tenant_id = ?))) AND (o.tenant_id = ?)"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{1, 1}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+}
+
+func TestPolicy_Errors(t *testing.T) {
+	tenant := Policy{Table: "Orders", Predicate: New("tenant_id = ?", 7)}
+
+	tests := []*Query{
+		New("SELECT * FROM orders").WithPolicy(tenant),
+		New("DELETE FROM \"orders\" ?", Optional("WHERE").Guard("users")).WithPolicy(tenant),
+		New("SELECT * FROM orders ? UNION SELECT * FROM orders", Optional("WHERE").Guard()).WithPolicy(tenant),
+	}
+	for _, q := range tests {
+		if _, _, err := q.ToSql(); err == nil || !strings.Contains(err.Error(), "guarded table Orders") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	if _, _, err := New("SELECT * FROM customers").WithPolicy(tenant).ToSql(); err != nil {
+		t.Errorf("got error: %v", err)
+	}
+}
+
+func TestPolicy_Label(t *testing.T) {
+	AddPolicy(Policy{Table: "orders", Label: "where.tenant"})
+	defer ClearPolicies()
+
+	where := Optional("WHERE").Guard("orders")
+	q := New("SELECT * FROM orders ?", where)
+	if _, _, err := q.ToSql(); err == nil || !strings.Contains(err.Error(), "requires where.tenant in WHERE") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	where.And("tenant_id = ?", 3).Label("where.tenant")
+	sql, _, err := q.ToSql()
+	if err != nil || sql != "SELECT * FROM orders WHERE tenant_id = ?" {
+		t.Errorf("unexpected sql: %q %v", sql, err)
+	}
+
+	// a predicate is only injected when the labelled part is missing
+	q = New("SELECT * FROM orders ?", where).
+		WithPolicy(Policy{Table: "orders", Label: "where.tenant", Predicate: New("tenant_id = ?", 4)})
+	sql, params, _ := q.ToSql()
+	if sql != "SELECT * FROM orders WHERE tenant_id = ?" || !reflect.DeepEqual(params, []any{3}) {
+		t.Errorf("unexpected sql: %q %v", sql, params)
+	}
+}
+
+func TestPolicy_Join(t *testing.T) {
+	q := New("SELECT * FROM orders").Space("?", Optional("WHERE").Guard("orders")).
+		WithPolicy(Policy{Table: "orders", Predicate: New("tenant_id = ?", 7)})
+	sql, _, err := q.ToSql()
+	if err != nil || sql != "SELECT * FROM orders WHERE (tenant_id = ?)" {
+		t.Errorf("unexpected sql: %q %v", sql, err)
+	}
+}
+
+func TestPolicy_Newline(t *testing.T) {
+	where := Optional("WHERE").Guard("orders").And("status = 'a'\nOR status = 'b'")
+	q := New("SELECT * FROM orders ?", where).
+		WithPolicy(Policy{Table: "orders", Predicate: New("tenant_id = ?\nOR tenant_id IS NULL", 7)})
+
+	sql, _, err := q.ToSql()
+	want := "SELECT * FROM orders WHERE (status = 'a'\nOR status = 'b') AND (tenant_id = ?\nOR tenant_id IS NULL)"
+	if err != nil || sql != want {
+		t.Errorf("\n got: %q\nwant: %q (%v)", sql, want, err)
+	}
+}
+
+func TestPolicy_Scopes(t *testing.T) {
+	tenant := Policy{Table: "orders", Predicate: New("o.tenant_id = ?", 7)}
+
+	tests := map[string]*Query{
+		"comma join": New("SELECT * FROM users u, orders o WHERE u.id = o.user_id").WithPolicy(tenant),
+		"comma join after subquery": New("SELECT * FROM (SELECT 1) AS x, public.\"Orders\" o ?",
+			Optional("WHERE").Guard("users")).WithPolicy(tenant),
+		"fragment": New("SELECT * FROM ?", ByDialect{SQL: New("orders o")}).WithPolicy(tenant),
+		"union":    New("SELECT id FROM orders UNION SELECT id FROM archive ?", Optional("WHERE").Guard()).WithPolicy(tenant),
+		"misplaced": New("SELECT id FROM orders UNION SELECT id FROM archive ?",
+			Optional("WHERE").Guard("orders")).WithPolicy(tenant),
+		"outer guard for subquery": New("SELECT * FROM users WHERE id IN (SELECT user_id FROM orders) ?",
+			Optional("AND").Guard()).WithPolicy(tenant),
+		"second statement": New("SELECT * FROM orders ?; DELETE FROM orders", Optional("WHERE").Guard()).WithPolicy(tenant),
+	}
+	for name, q := range tests {
+		if _, _, err := q.ToSql(); err == nil || !strings.Contains(err.Error(), "orders") {
+			t.Errorf("%v: unexpected error: %v", name, err)
+		}
+	}
+
+	valid := New("SELECT id FROM archive UNION SELECT id FROM users u, orders o ?",
+		Optional("WHERE").Guard()).WithPolicy(tenant)
+	sql, _, err := valid.ToSql()
+	want := "SELECT id FROM archive UNION SELECT id FROM users u, orders o WHERE (o.tenant_id = ?)"
+	if err != nil || sql != want {
+		t.Errorf("\n got: %q\nwant: %q (%v)", sql, want, err)
+	}
+
+	valid = New("SELECT * FROM ? ?", ByDialect{SQL: New("orders o")}, Optional("WHERE").Guard("orders").And("?", After([]string{"id"}, []any{3}))).
+		WithPolicy(tenant)
+	sql, params, err := valid.ToDialect(MSSQL)
+	want = "SELECT * FROM orders o WHERE (id > @p1) AND (o.tenant_id = @p2)"
+	if err != nil || sql != want {
+		t.Errorf("\n got: %q\nwant: %q (%v)", sql, want, err)
+	}
+	if !reflect.DeepEqual(params, []any{3, 7}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+}
+
+func TestPolicy_Insert(t *testing.T) {
+	AddPolicy(Policy{Table: "orders", Predicate: New("tenant_id = ?", 7)})
+	defer ClearPolicies()
+
+	for _, dialect := range []Dialect{PGSQL, MYSQL} {
+		q := Upsert("orders", []string{"id", "status"}, []string{"id"}, []string{"status"}, 1, "open")
+		if _, _, err := q.ToDialect(dialect); err != nil {
+			t.Errorf("%v: got error: %v", dialect, err)
+		}
+	}
+	if _, _, err := New("INSERT IGNORE INTO orders (id) VALUES (?)", 1).ToMysql(); err != nil {
+		t.Errorf("got error: %v", err)
+	}
+
+	// tables read by the INSERT are still guarded
+	q := New("INSERT INTO orders (id) SELECT id FROM orders")
+	if _, _, err := q.ToSql(); err == nil || !strings.Contains(err.Error(), "orders") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	q = New("INSERT INTO orders (id) SELECT id FROM orders ?", Optional("WHERE").Guard())
+	sql, _, err := q.ToSql()
+	if want := "INSERT INTO orders (id) SELECT id FROM orders WHERE (tenant_id = ?)"; err != nil || sql != want {
+		t.Errorf("\n got: %q\nwant: %q (%v)", sql, want, err)
+	}
+}
+
+func TestPolicy_Secret(t *testing.T) {
+	tenant := Policy{Table: "orders", Predicate: New("tenant_id = ?", 7)}
+
+	where := Optional("WHERE").Guard("orders").And("ssn = ?", "123")
+	q := New("SELECT * FROM orders ?", Secret(where)).WithPolicy(tenant)
+
+	sql, params, err := q.ToPgsql()
+	want := "SELECT * FROM orders WHERE (ssn = $1) AND (tenant_id = $2)"
+	if err != nil || sql != want {
+		t.Errorf("\n got: %q\nwant: %q (%v)", sql, want, err)
+	}
+	if !reflect.DeepEqual(params, []any{"123", 7}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	sql, _ = q.ToRaw()
+	if want := "SELECT * FROM orders WHERE (ssn = [REDACTED]) AND (tenant_id = 7)"; sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+}
@@ -3,7 +3,9 @@ package bqb
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 )
 
 // QueryPart holds a section of a Query.
@@ -11,6 +13,10 @@ type QueryPart struct {
 	Text   string
 	Params []any
 	Errs   []error
+	Label  string
+
+	sep        string
+	subqueries []subquery
 }
 
 // Query contains all the QueryParts for the query and is the primary
@@ -18,6 +24,17 @@ type QueryPart struct {
 type Query struct {
 	Parts          []QueryPart
 	OptionalPrefix string
+	OptionalSuffix string
+
+	hooks    []Hook
+	comments map[string]string
+	grouped  bool
+	policies []Policy
+	guarded  bool
+	guards   guardTables
+	// last is the number of the QueryPart added by the last call, used by
+	// Label: 0 is unknown, meaning the last QueryPart, and -1 is none.
+	last int
 }
 
 // New returns an instance of Query with a single QueryPart.
@@ -40,6 +57,17 @@ func Optional(prefix string) *Query {
 	}
 }
 
+// OptionalWrap returns a query object that has a conditional prefix and
+// suffix which only resolve when at least one QueryPart has been added.
+// The prefix and suffix are joined with a space, except after an opening
+// or before a closing bracket, e.g. OptionalWrap("(", ")") renders `(a)`.
+func OptionalWrap(prefix, suffix string) *Query {
+	return &Query{
+		OptionalPrefix: prefix,
+		OptionalSuffix: suffix,
+	}
+}
+
 // And joins the current QueryPart to the previous QueryPart with ' AND '.
 func (q *Query) And(text string, args ...any) *Query {
 	if q == nil {
@@ -48,6 +76,24 @@ func (q *Query) And(text string, args ...any) *Query {
 	return q.Join(" AND ", text, args...)
 }
 
+// AndIf calls And only when cond is true.
+func (q *Query) AndIf(cond bool, text string, args ...any) *Query {
+	if !cond {
+		return q.skip()
+	}
+	return q.And(text, args...)
+}
+
+// AndPtr calls And with the value ptr points to, or does nothing when
+// ptr is nil.
+func (q *Query) AndPtr(text string, ptr any) *Query {
+	arg, ok := derefPtr(ptr)
+	if !ok {
+		return q.skip()
+	}
+	return q.And(text, arg)
+}
+
 // Comma joins the current QueryPart to the previous QueryPart with a comma.
 func (q *Query) Comma(text string, args ...any) *Query {
 	if q == nil {
@@ -56,6 +102,24 @@ func (q *Query) Comma(text string, args ...any) *Query {
 	return q.Join(",", text, args...)
 }
 
+// CommaIf calls Comma only when cond is true.
+func (q *Query) CommaIf(cond bool, text string, args ...any) *Query {
+	if !cond {
+		return q.skip()
+	}
+	return q.Comma(text, args...)
+}
+
+// CommaPtr calls Comma with the value ptr points to, or does nothing when
+// ptr is nil.
+func (q *Query) CommaPtr(text string, ptr any) *Query {
+	arg, ok := derefPtr(ptr)
+	if !ok {
+		return q.skip()
+	}
+	return q.Comma(text, arg)
+}
+
 // Concat concatenates the current QueryPart to the previous QueryPart with a
 // zero space string.
 func (q *Query) Concat(text string, args ...any) *Query {
@@ -65,6 +129,24 @@ func (q *Query) Concat(text string, args ...any) *Query {
 	return q.Join("", text, args...)
 }
 
+// ConcatIf calls Concat only when cond is true.
+func (q *Query) ConcatIf(cond bool, text string, args ...any) *Query {
+	if !cond {
+		return q.skip()
+	}
+	return q.Concat(text, args...)
+}
+
+// ConcatPtr calls Concat with the value ptr points to, or does nothing when
+// ptr is nil.
+func (q *Query) ConcatPtr(text string, ptr any) *Query {
+	arg, ok := derefPtr(ptr)
+	if !ok {
+		return q.skip()
+	}
+	return q.Concat(text, arg)
+}
+
 // Empty returns true if the Query is nil or has a length > 0.
 func (q *Query) Empty() bool {
 	if q == nil {
@@ -73,20 +155,83 @@ func (q *Query) Empty() bool {
 	return q.Len() == 0
 }
 
+// Insert adds a QueryPart at index, joined to the previous QueryPart with
+// `sep`. When index is 0 the QueryPart that was first is joined to it with
+// `sep` instead. q.Parts is replaced rather than changed, so copies of q
+// are unaffected.
+func (q *Query) Insert(index int, sep, text string, args ...any) *Query {
+	if q == nil {
+		q = Q()
+	}
+	if index < 0 || index > len(q.Parts) {
+		part := makePart(text, args...)
+		part.Errs = append(part.Errs, fmt.Errorf("insert index %d out of range (%d parts)", index, len(q.Parts)))
+		q.Parts = append(q.Parts, part)
+		q.last = len(q.Parts)
+		return q
+	}
+
+	parts := make([]QueryPart, 0, len(q.Parts)+1)
+	parts = append(parts, q.Parts[:index]...)
+	if index > 0 {
+		part := makePart(sep+text, args...)
+		part.sep = sep
+		parts = append(parts, part)
+	} else {
+		parts = append(parts, makePart(text, args...))
+	}
+	parts = append(parts, q.Parts[index:]...)
+	if index == 0 && len(parts) > 1 {
+		parts[1].Text = sep + parts[1].Text
+		parts[1].sep = sep
+	}
+	q.Parts = parts
+	q.last = index + 1
+	return q
+}
+
 // Join joins the current QueryPart to the previous QueryPart with `sep`.
+//
+// Note: When every argument is an empty Query nothing is added, so that an
+// empty group leaves q unchanged however it is joined, e.g.
+// q.And("(?)", All()).
 func (q *Query) Join(sep, text string, args ...any) *Query {
 	if q == nil {
 		return New(text, args...)
 	}
+	if allEmpty(args) {
+		return q.skip()
+	}
 	if len(q.Parts) > 0 {
-		q.Parts = append(q.Parts, makePart(sep+text, args...))
+		part := makePart(sep+text, args...)
+		part.sep = sep
+		q.Parts = append(q.Parts, part)
 	} else {
 		q.Parts = append(q.Parts, makePart(text, args...))
 	}
+	q.last = len(q.Parts)
 
 	return q
 }
 
+// JoinIf calls Join only when cond is true.
+func (q *Query) JoinIf(cond bool, sep, text string, args ...any) *Query {
+	if !cond {
+		return q.skip()
+	}
+	return q.Join(sep, text, args...)
+}
+
+// JoinPtr calls Join with the value ptr points to, or does nothing when
+// ptr is nil.
+func (q *Query) JoinPtr(sep, text string, ptr any) *Query {
+	arg, ok := derefPtr(ptr)
+	if !ok {
+		return q.skip()
+	}
+	return q.Join(sep, text, arg)
+}
+
 // Len returns the length of Query.Parts
 func (q *Query) Len() int {
 	if q == nil {
@@ -103,12 +248,83 @@ func (q *Query) Or(text string, args ...any) *Query {
 	return q.Join(" OR ", text, args...)
 }
 
-// Print outputs the sql, parameters, and errors of a Query.
+// OrIf calls Or only when cond is true.
+func (q *Query) OrIf(cond bool, text string, args ...any) *Query {
+	if !cond {
+		return q.skip()
+	}
+	return q.Or(text, args...)
+}
+
+// OrPtr calls Or with the value ptr points to, or does nothing when
+// ptr is nil.
+func (q *Query) OrPtr(text string, ptr any) *Query {
+	arg, ok := derefPtr(ptr)
+	if !ok {
+		return q.skip()
+	}
+	return q.Or(text, arg)
+}
+
+// Print outputs the sql, parameters, and errors of a Query to stdout.
 func (q *Query) Print() {
-	sql, params, err := q.ToSql()
-	fmt.Printf("SQL: %v\n", sql)
-	fmt.Printf("PARAMS: %v\n", params)
-	fmt.Printf("ERROR: %v\n", err)
+	q.PrintTo(os.Stdout)
+}
+
+// Remove removes the QueryParts labelled with label, or does nothing when
+// label is empty. When the first QueryPart is removed, the separator of the
+// next one is dropped. q.Parts is replaced rather than changed, so copies
+// of q are unaffected.
+// Note: Only the QueryParts of q are removed, not those of subqueries.
+func (q *Query) Remove(label string) *Query {
+	if q == nil || label == "" {
+		return q
+	}
+	var parts []QueryPart
+	for _, p := range q.Parts {
+		if p.Label == label {
+			continue
+		}
+		if len(parts) == 0 && p.sep != "" {
+			p.Text = strings.TrimPrefix(p.Text, p.sep)
+			p.sep = ""
+		}
+		parts = append(parts, p)
+	}
+	q.Parts = parts
+	q.last = -1
+	return q
+}
+
+// Replace replaces the text and args of the QueryParts labelled with
+// label, keeping their separator and label, or does nothing when label is
+// empty. q.Parts is replaced rather than changed, so copies of q are
+// unaffected.
+// Note: Only the QueryParts of q are replaced, not those of subqueries.
+func (q *Query) Replace(label, text string, args ...any) *Query {
+	if q == nil || label == "" {
+		return q
+	}
+	parts := make([]QueryPart, len(q.Parts))
+	for i, p := range q.Parts {
+		if p.Label == label {
+			p = makePart(p.sep+text, args...)
+			p.sep = q.Parts[i].sep
+			p.Label = label
+		}
+		parts[i] = p
+	}
+	q.Parts = parts
+	return q
+}
+
+// skip records that the last call added no QueryPart, so that a following
+// Label does not label an earlier one.
+func (q *Query) skip() *Query {
+	if q != nil {
+		q.last = -1
+	}
+	return q
 }
 
 // Space joins the current QueryPart to the previous QueryPart with a space.
@@ -119,47 +335,92 @@ func (q *Query) Space(text string, args ...any) *Query {
 	return q.Join(" ", text, args...)
 }
 
+// SpaceIf calls Space only when cond is true.
+func (q *Query) SpaceIf(cond bool, text string, args ...any) *Query {
+	if !cond {
+		return q.skip()
+	}
+	return q.Space(text, args...)
+}
+
+// SpacePtr calls Space with the value ptr points to, or does nothing when
+// ptr is nil.
+func (q *Query) SpacePtr(text string, ptr any) *Query {
+	arg, ok := derefPtr(ptr)
+	if !ok {
+		return q.skip()
+	}
+	return q.Space(text, arg)
+}
+
+// ToDialect returns the sql with the placeholder format used by dialect.
+// Params are nil for the RAW dialect since they are embedded in the sql.
+func (q *Query) ToDialect(dialect Dialect) (string, []any, error) {
+	return q.build(dialect)
+}
+
 // ToMysql returns the sql placeholders with SQL (?) format used by MySQL
 func (q *Query) ToMysql() (string, []any, error) {
-	sql, params, err := q.toSql()
-	if err != nil {
-		return "", nil, err
-	}
-	sql, err = dialectReplace(MYSQL, sql, params)
-	return sql, params, err
+	return q.build(MYSQL)
 }
 
 // ToPgsql returns the sql placeholders with dollarsign format used by postgres.
 func (q *Query) ToPgsql() (string, []any, error) {
-	sql, params, err := q.toSql()
-	if err != nil {
-		return "", nil, err
-	}
-	sql, err = dialectReplace(PGSQL, sql, params)
-	return sql, params, err
+	return q.build(PGSQL)
 }
 
 // ToRaw returns a string which the parameters have been resolved added
 // as correctly as possible.
 func (q *Query) ToRaw() (string, error) {
-	sql, params, err := q.toSql()
-	if err != nil {
-		return "", err
-	}
-
-	sql, err = dialectReplace(RAW, sql, params)
+	sql, _, err := q.build(RAW)
 	return sql, err
 }
 
 // ToSql returns the placeholders with question (?) format used by most
 // databases such as sqlite, mysql, and others.
 func (q *Query) ToSql() (string, []any, error) {
+	return q.build(SQL)
+}
+
+// build compiles the Query for dialect and runs the registered hooks.
+func (q *Query) build(dialect Dialect) (string, []any, error) {
+	start := time.Now()
+	sql, params, err := q.compile(dialect)
+
+	return q.runHooks(&BuildEvent{
+		Dialect: dialect,
+		SQL:     sql,
+		Params:  unwrapSecrets(params),
+		Elapsed: time.Since(start),
+		Err:     err,
+	})
+}
+
+// compile returns the SQL for dialect, leaving any Redacted params wrapped.
+func (q *Query) compile(dialect Dialect) (string, []any, error) {
 	sql, params, err := q.toSql()
+	if err == nil {
+		sql, params, err = resolveFragments(dialect, sql, params)
+	}
+	if err == nil {
+		sql, params, err = q.applyPolicies(dialect, sql, params)
+	}
+	if err == nil && dialect == MSSQL {
+		sql, err = placeOutput(sql)
+	}
+	if err == nil {
+		sql, err = dialectReplace(dialect, sql, params)
+	}
+	if err == nil {
+		sql, err = appendComment(sql, q.comments)
+	}
 	if err != nil {
 		return "", nil, err
 	}
-	sql, err = dialectReplace(SQL, sql, params)
-	return sql, params, err
+	if dialect == RAW {
+		params = nil
+	}
+	return sql, params, nil
 }
 
 func (q *Query) toSql() (string, []any, error) {
@@ -170,7 +431,10 @@ func (q *Query) toSql() (string, []any, error) {
 	var params []any
 
 	if q.OptionalPrefix != "" && len(q.Parts) > 0 {
-		sql = q.OptionalPrefix + " "
+		sql = q.OptionalPrefix
+		if !strings.HasSuffix(sql, "(") && !strings.HasSuffix(sql, "[") {
+			sql += " "
+		}
 	}
 
 	for _, p := range q.Parts {
@@ -182,5 +446,13 @@ func (q *Query) toSql() (string, []any, error) {
 		}
 	}
 
+	if q.OptionalSuffix != "" && len(q.Parts) > 0 {
+		sql = strings.TrimSpace(sql)
+		if !strings.HasPrefix(q.OptionalSuffix, ")") && !strings.HasPrefix(q.OptionalSuffix, "]") {
+			sql += " "
+		}
+		sql += q.OptionalSuffix
+	}
+
 	return strings.TrimSpace(sql), params, nil
 }
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -787,3 +788,213 @@ func Benchmark_ToRaw_Params(b *testing.B) {
 		}
 	}
 }
+
+func TestQuery_If(t *testing.T) {
+	q := New("a").
+		AndIf(true, "b = ?", 1).AndIf(false, "x").
+		OrIf(true, "c").OrIf(false, "x").
+		SpaceIf(true, "d").SpaceIf(false, "x").
+		CommaIf(true, "e").CommaIf(false, "x").
+		ConcatIf(true, "f").ConcatIf(false, "x").
+		JoinIf(true, "+", "g").JoinIf(false, "+", "x")
+
+	sql, params, _ := q.ToSql()
+	want := "a AND b = ? OR c d,ef+g"
+	if sql != want {
+		t.Errorf("got: %q, want: %q", sql, want)
+	}
+	if len(params) != 1 {
+		t.Errorf("got incorrect param count: %v", len(params))
+	}
+
+	var qNil *Query
+	if qNil.AndIf(false, "a") != nil {
+		t.Errorf("expected nil query")
+	}
+}
+
+func TestQuery_Ptr(t *testing.T) {
+	name := "bob"
+	age := 30
+	var nilInt *int
+	var nilQuery *Query
+
+	where := Optional("WHERE").
+		AndPtr("name = ?", &name).AndPtr("x = ?", nilInt).AndPtr("x = ?", nil).
+		OrPtr("age = ?", &age).OrPtr("x = ?", nilInt).
+		SpacePtr("?", New("AND 1 = 1")).SpacePtr("?", nilQuery).
+		CommaPtr("?", 1).CommaPtr("x = ?", nilInt).
+		ConcatPtr("?", &name).ConcatPtr("x = ?", nilInt).
+		JoinPtr("+", "?", []int{1, 2}).JoinPtr("+", "x = ?", nilInt)
+
+	sql, err := where.ToRaw()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	want := "WHERE name = 'bob' OR age = 30 AND 1 = 1,1'bob'+1,2"
+	if sql != want {
+		t.Errorf("got: %q, want: %q", sql, want)
+	}
+
+	if !Optional("WHERE").AndPtr("x = ?", nilInt).Empty() {
+		t.Errorf("expected empty query for nil pointer")
+	}
+
+	sql, params, _ := New("a").AndPtr("b = ?", &ptrValuer{"x"}).AndPtr("c = ?", &ptrEmbedder{}).ToSql()
+	if sql != "a AND b = ? AND c = raw" || !reflect.DeepEqual(params, []any{"v:x"}) {
+		t.Errorf("unexpected sql: %q %v", sql, params)
+	}
+}
+
+type ptrValuer struct {
+	v string
+}
+
+func (p *ptrValuer) Value() (driver.Value, error) {
+	return "v:" + p.v, nil
+}
+
+type ptrEmbedder struct{}
+
+func (*ptrEmbedder) RawValue() string {
+	return "raw"
+}
+
+func TestOptionalWrap(t *testing.T) {
+	upsert := OptionalWrap("ON CONFLICT (id) DO UPDATE SET", "RETURNING id")
+	q := New("INSERT INTO users (id, name) VALUES (?, ?)", 1, "a").Space("?", upsert)
+
+	sql, _ := q.ToRaw()
+	want := "INSERT INTO users (id, name) VALUES (1, 'a')"
+	if sql != want {
+		t.Errorf("got: %q, want: %q", sql, want)
+	}
+
+	upsert.Comma("name = EXCLUDED.name")
+	q = New("INSERT INTO users (id, name) VALUES (?, ?)", 1, "a").Space("?", upsert)
+	sql, _ = q.ToRaw()
+	want = "INSERT INTO users (id, name) VALUES (1, 'a') ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name RETURNING id"
+	if sql != want {
+		t.Errorf("got: %q, want: %q", sql, want)
+	}
+
+	group := OptionalWrap("(", ")")
+	if !group.Empty() {
+		t.Errorf("OptionalWrap is not empty")
+	}
+	group.Or("a = ?", 1).Or("b = ?", 2)
+	sql, params, _ := New("SELECT * FROM t WHERE c AND ?", group).ToPgsql()
+	want = "SELECT * FROM t WHERE c AND (a = $1 OR b = $2)"
+	if sql != want {
+		t.Errorf("got: %q, want: %q", sql, want)
+	}
+	if len(params) != 2 {
+		t.Errorf("got incorrect param count: %v", len(params))
+	}
+}
+
+func TestQuery_Remove(t *testing.T) {
+	where := Optional("WHERE").
+		And("a = ?", 1).Label("where.a").
+		And("b = ?", 2).Label("where.b").
+		Or("c = ?", 3).Label("where.c")
+
+	where.Remove("where.b")
+	sql, params, _ := where.ToSql()
+	if sql != "WHERE a = ? OR c = ?" || fmt.Sprint(params) != "[1 3]" {
+		t.Errorf("unexpected sql: %q %v", sql, params)
+	}
+
+	where.Remove("where.a")
+	sql, params, _ = where.ToSql()
+	if sql != "WHERE c = ?" || fmt.Sprint(params) != "[3]" {
+		t.Errorf("unexpected sql: %q %v", sql, params)
+	}
+
+	where.Remove("missing").Remove("")
+	if where.Len() != 1 {
+		t.Errorf("expected missing and empty labels to be ignored, got %d parts", where.Len())
+	}
+	where.Remove("where.c")
+	if !where.Empty() {
+		t.Errorf("expected empty query, got %d parts", where.Len())
+	}
+	sql, _, _ = New("SELECT * FROM t ?", where).ToSql()
+	if sql != "SELECT * FROM t" {
+		t.Errorf("unexpected sql: %q", sql)
+	}
+
+	var q *Query
+	if q.Remove("x") != nil || q.Replace("x", "y") != nil {
+		t.Error("expected nil query")
+	}
+}
+
+func TestQuery_Replace(t *testing.T) {
+	q := New("SELECT * FROM t").Space("ORDER BY id").Label("order").Space("LIMIT ?", 10)
+	q.Replace("order", "ORDER BY ? DESC", Embedded("name"))
+
+	sql, params, err := q.ToSql()
+	if err != nil || sql != "SELECT * FROM t ORDER BY name DESC LIMIT ?" || fmt.Sprint(params) != "[10]" {
+		t.Errorf("unexpected sql: %q %v %v", sql, params, err)
+	}
+	if q.Find("order") == nil {
+		t.Error("expected label to be kept")
+	}
+
+	q.Replace("order", "?")
+	if _, _, err := q.ToSql(); err == nil || !strings.Contains(err.Error(), "extra ?") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestQuery_Insert(t *testing.T) {
+	q := New("a = ?", 1).And("c = ?", 3)
+	q.Insert(1, " AND ", "b = ?", 2)
+	q.Insert(0, " OR ", "z = ?", 0)
+	q.Insert(4, " AND ", "d = ?", 4)
+
+	sql, params, err := q.ToSql()
+	if err != nil || sql != "z = ? OR a = ? AND b = ? AND c = ? AND d = ?" || fmt.Sprint(params) != "[0 1 2 3 4]" {
+		t.Errorf("unexpected sql: %q %v %v", sql, params, err)
+	}
+
+	// removing the inserted first part drops the separator it gave the next part
+	q.Parts[0].Label = "z"
+	sql, _, _ = q.Remove("z").ToSql()
+	if sql != "a = ? AND b = ? AND c = ? AND d = ?" {
+		t.Errorf("unexpected sql: %q", sql)
+	}
+
+	sql, _, _ = Optional("WHERE").Insert(0, " AND ", "x = 1").ToSql()
+	if sql != "WHERE x = 1" {
+		t.Errorf("unexpected sql: %q", sql)
+	}
+
+	if _, _, err := New("a").Insert(3, " ", "b").ToSql(); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestQuery_EditCopy(t *testing.T) {
+	base := New("a = ?", 1).Label("a").And("b = ?", 2).Label("b").And("c = ?", 3)
+	want := "a = ? AND b = ? AND c = ?"
+
+	edits := []func(q *Query){
+		func(q *Query) { q.Remove("a") },
+		func(q *Query) { q.Remove("b") },
+		func(q *Query) { q.Replace("b", "x = ?", 4) },
+		func(q *Query) { q.Insert(1, " OR ", "y") },
+	}
+	for i, edit := range edits {
+		v := *base
+		edit(&v)
+		if sql, params, _ := base.ToSql(); sql != want || fmt.Sprint(params) != "[1 2 3]" {
+			t.Errorf("edit %d changed base: %q %v", i, sql, params)
+		}
+	}
+
+	if base.Replace("", "x").Find("") != nil {
+		t.Error("expected empty label to be ignored")
+	}
+}
@@ -0,0 +1,164 @@
+package bqb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type returning struct {
+	cols []string
+}
+
+func (r returning) render(dialect Dialect) (*Query, error) {
+	switch dialect {
+	case MYSQL, ORACLE:
+		return nil, fmt.Errorf("RETURNING is not supported for dialect: %v", dialect)
+	case MSSQL:
+		// SQL Server needs an OUTPUT clause inside the statement, which is
+		// moved into place by placeOutput.
+		return New(outputPh + strings.Join(r.cols, ",") + outputPh), nil
+	default:
+		return New("RETURNING " + strings.Join(r.cols, ",")), nil
+	}
+}
+
+// Returning adds a RETURNING clause for cols to the Query. For SQL Server
+// an OUTPUT clause is placed within the INSERT, UPDATE or DELETE statement
+// instead, and MySQL and Oracle return an error.
+func (q *Query) Returning(cols ...string) *Query {
+	return q.Space("?", returning{cols: cols})
+}
+
+// placeOutput moves each OUTPUT clause rendered for Returning into place
+// within its statement for SQL Server.
+func placeOutput(sql string) (string, error) {
+	var out strings.Builder
+	for placed := false; ; placed = true {
+		start := strings.Index(sql, outputPh)
+		if start < 0 {
+			out.WriteString(sql)
+			return out.String(), nil
+		}
+		n := strings.Index(sql[start+len(outputPh):], outputPh)
+		if n < 0 {
+			return "", errors.New("unterminated OUTPUT clause")
+		}
+		end := start + len(outputPh) + n
+		cols := strings.Split(sql[start+len(outputPh):end], ",")
+
+		head := sql[:start]
+		semi, depth := lastStatement(head)
+		if depth != 0 {
+			return "", errors.New("cannot place OUTPUT clause in a subquery")
+		}
+		if semi < 0 && placed {
+			return "", errors.New("cannot place more than one OUTPUT clause in a statement")
+		}
+
+		stmt := head[semi+1:]
+		lead := stmt[:len(stmt)-len(strings.TrimLeftFunc(stmt, unicode.IsSpace))]
+		stmt, err := placeOutputStmt(strings.TrimSpace(stmt), cols)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(head[:semi+1] + lead + stmt)
+		sql = sql[end+len(outputPh):]
+	}
+}
+
+// placeOutputStmt returns stmt with an OUTPUT clause for cols.
+func placeOutputStmt(stmt string, cols []string) (string, error) {
+	table := "INSERTED."
+	var pos int
+	switch kind := strings.ToUpper(firstWord(stmt)); kind {
+	case "INSERT":
+		pos = topLevelIndex(stmt, "VALUES", "SELECT", "DEFAULT")
+		if pos < 0 {
+			return "", fmt.Errorf("cannot place OUTPUT clause in: %v", stmt)
+		}
+	case "UPDATE":
+		pos = topLevelIndex(stmt, "FROM", "WHERE")
+	case "DELETE":
+		table = "DELETED."
+		pos = topLevelIndex(stmt, "WHERE")
+	default:
+		return "", fmt.Errorf("cannot place OUTPUT clause in %v statement", kind)
+	}
+	if pos < 0 {
+		pos = len(stmt)
+	}
+
+	for i, col := range cols {
+		cols[i] = table + col
+	}
+	output := "OUTPUT " + strings.Join(cols, ",")
+	return strings.TrimSpace(strings.TrimSpace(stmt[:pos]) + " " + output + " " + stmt[pos:]), nil
+}
+
+// lastStatement returns the index of the last semicolon in sql outside of
+// parentheses and quotes, or -1, and the depth of parentheses at its end.
+func lastStatement(sql string) (int, int) {
+	semi, depth := -1, 0
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ';' && depth == 0:
+			semi = i
+		}
+	}
+	return semi, depth
+}
+
+func firstWord(s string) string {
+	if i := strings.IndexFunc(s, unicode.IsSpace); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// topLevelIndex returns the index of the first of keywords that appears in
+// sql outside of parentheses and quotes, or -1.
+func topLevelIndex(sql string, keywords ...string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && (i == 0 || !isWordByte(sql[i-1])):
+			for _, kw := range keywords {
+				j := i + len(kw)
+				if j <= len(sql) && strings.EqualFold(sql[i:j], kw) && (j == len(sql) || !isWordByte(sql[j])) {
+					return i
+				}
+			}
+		}
+	}
+	return -1
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
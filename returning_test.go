@@ -0,0 +1,93 @@
+package bqb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuery_Returning(t *testing.T) {
+	q := New("INSERT INTO users (name, note) VALUES (?, 'a (where) b')", "ed").Returning("id", "created")
+
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{PGSQL, "INSERT INTO users (name, note) VALUES ($1, 'a (where) b') RETURNING id,created"},
+		{SQL, "INSERT INTO users (name, note) VALUES (?, 'a (where) b') RETURNING id,created"},
+		{MARIADB, "INSERT INTO users (name, note) VALUES (?, 'a (where) b') RETURNING id,created"},
+		{MSSQL, "INSERT INTO users (name, note) OUTPUT INSERTED.id,INSERTED.created VALUES (@p1, 'a (where) b')"},
+	}
+	for _, tt := range tests {
+		sql, params, err := q.ToDialect(tt.dialect)
+		if err != nil {
+			t.Errorf("%v: got error: %v", tt.dialect, err)
+		}
+		if sql != tt.want {
+			t.Errorf("%v:\n got: %q\nwant: %q", tt.dialect, sql, tt.want)
+		}
+		if !reflect.DeepEqual(params, []any{"ed"}) {
+			t.Errorf("%v: unexpected params: %v", tt.dialect, params)
+		}
+	}
+
+	_, _, err := q.ToMysql()
+	if err == nil || !strings.Contains(err.Error(), "RETURNING is not supported") {
+		t.Errorf("expected unsupported error for mysql, got: %v", err)
+	}
+}
+
+func TestQuery_Returning_Mssql(t *testing.T) {
+	tests := []struct {
+		q    *Query
+		want string
+	}{
+		{
+			New("UPDATE users SET name = ? WHERE id = ?", "ed", 1).Returning("*"),
+			"UPDATE users SET name = @p1 OUTPUT INSERTED.* WHERE id = @p2",
+		},
+		{
+			New("UPDATE users SET name = (SELECT name FROM x WHERE id = 1)").Returning("id"),
+			"UPDATE users SET name = (SELECT name FROM x WHERE id = 1) OUTPUT INSERTED.id",
+		},
+		{
+			New("DELETE FROM users WHERE id = ?", 1).Returning("id"),
+			"DELETE FROM users OUTPUT DELETED.id WHERE id = @p1",
+		},
+		{
+			New("insert into users (id) select id from old").Returning("id").Comment(map[string]string{"a": "b"}),
+			"insert into users (id) OUTPUT INSERTED.id select id from old /*a='b'*/",
+		},
+		{
+			New("INSERT INTO users DEFAULT VALUES").Returning("id").Space("; SELECT 1"),
+			"INSERT INTO users OUTPUT INSERTED.id DEFAULT VALUES ; SELECT 1",
+		},
+		{
+			New("UPDATE a SET x = ';' WHERE id = ?", 1).Returning("id").
+				Space("; DELETE FROM b WHERE id = ?", 2).Returning("id", "x"),
+			"UPDATE a SET x = ';' OUTPUT INSERTED.id WHERE id = @p1 ; DELETE FROM b OUTPUT DELETED.id,DELETED.x WHERE id = @p2",
+		},
+	}
+	for _, tt := range tests {
+		sql, _, err := tt.q.ToDialect(MSSQL)
+		if err != nil {
+			t.Errorf("got error: %v", err)
+		}
+		if sql != tt.want {
+			t.Errorf("\n got: %q\nwant: %q", sql, tt.want)
+		}
+	}
+
+	for _, q := range []*Query{
+		New("SELECT 1").Returning("id"),
+		New("INSERT INTO users").Returning("id"),
+		New("DELETE FROM a").Returning("id").Returning("x"),
+		New("SELECT * FROM (?) AS d", New("DELETE FROM a").Returning("id")),
+		New("WITH d AS (?) SELECT * FROM d", New("DELETE FROM a").Returning("id")),
+		New("DELETE FROM a " + outputPh + "id"),
+	} {
+		if _, _, err := q.ToDialect(MSSQL); err == nil || !strings.Contains(err.Error(), "OUTPUT") {
+			t.Errorf("expected OUTPUT placement error, got: %v", err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package bqb
+
+import (
+	"strings"
+)
+
+type lexKind int
+
+const (
+	lexEOF lexKind = iota
+	// lexWord is an unquoted identifier or keyword.
+	lexWord
+	// lexQuoted is a quoted identifier.
+	lexQuoted
+	lexString
+	lexNumber
+	lexParam
+	lexOp
+	// lexInvalid is text that cannot be lexed, such as an unterminated
+	// string, holding the rest of the input.
+	lexInvalid
+)
+
+// lexToken is a token of SQL parsed by Check, with upper holding the upper
+// case text of words.
+type lexToken struct {
+	kind  lexKind
+	text  string
+	upper string
+	pos   int
+	end   int
+	// msg describes why a lexInvalid token is invalid.
+	msg string
+}
+
+// sqlOps are the operators lexed as a single token, longest first.
+var sqlOps = []string{
+	"->>", "#>>", "!~*", "<=>",
+	"::", "->", "#>", "@>", "<@", "<>", "!=", "<=", ">=", "||", "<<", ">>",
+	"?|", "?&", "!~", "~*", "&&", ":=", "=>",
+}
+
+// lexSql splits sql into tokens for the grammar g, ending with a lexEOF
+// token, or with a lexInvalid token at the first text that cannot be lexed.
+func lexSql(g *sqlGrammar, sql string) []lexToken {
+	var tokens []lexToken
+	add := func(kind lexKind, start, end int) {
+		text := sql[start:end]
+		tok := lexToken{kind: kind, text: text, pos: start, end: end}
+		if kind == lexWord {
+			tok.upper = strings.ToUpper(text)
+		}
+		tokens = append(tokens, tok)
+	}
+	invalid := func(start int, msg string) []lexToken {
+		return append(tokens, lexToken{kind: lexInvalid, text: sql[start:], pos: start, end: len(sql), msg: msg})
+	}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(sql[i:], "--") || c == '#' && g.hashComment:
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			i += end
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return invalid(i, "unterminated comment")
+			}
+			i += end + 4
+		case c == '\'':
+			end := quoteEnd(sql, i, g.backslashEscape)
+			if end < 0 {
+				return invalid(i, "unterminated string")
+			}
+			add(lexString, i, end)
+			i = end
+		case c == '"' && g.doubleQuoteString:
+			end := quoteEnd(sql, i, g.backslashEscape)
+			if end < 0 {
+				return invalid(i, "unterminated string")
+			}
+			add(lexString, i, end)
+			i = end
+		case c == '"' || c == '`' && g.backtick:
+			end := quoteEnd(sql, i, false)
+			if end < 0 {
+				return invalid(i, "unterminated quoted identifier")
+			}
+			add(lexQuoted, i, end)
+			i = end
+		case c == '[' && g.param == 0 && strings.HasPrefix(sql[i:], redactedText):
+			// Secret params rendered by ToRaw
+			add(lexParam, i, i+len(redactedText))
+			i += len(redactedText)
+		case c == '[' && g.bracket:
+			end := strings.IndexByte(sql[i:], ']')
+			if end < 0 {
+				return invalid(i, "unterminated quoted identifier")
+			}
+			add(lexQuoted, i, i+end+1)
+			i += end + 1
+		case isDigit(c) || c == '.' && i+1 < len(sql) && isDigit(sql[i+1]):
+			end := numberEnd(sql, i)
+			add(lexNumber, i, end)
+			i = end
+		case c == '$' && g.param == '$' && i+1 < len(sql) && isDigit(sql[i+1]),
+			c == ':' && g.param == ':' && i+1 < len(sql) && (isDigit(sql[i+1]) || isIdentStart(sql[i+1])),
+			c == '@' && g.param == '@' && i+1 < len(sql) && (isIdentByte(sql[i+1]) || sql[i+1] == '@'):
+			end := i + 1
+			for end < len(sql) && (isIdentByte(sql[end]) || sql[end] == '@') {
+				end++
+			}
+			add(lexParam, i, end)
+			i = end
+		case c == '?' && g.param == '?':
+			add(lexParam, i, i+1)
+			i++
+		case c == '$' && g.output && i+1 < len(sql) && isIdentStart(sql[i+1]):
+			// $action in the OUTPUT clause of MERGE
+			end := i + 1
+			for end < len(sql) && isIdentByte(sql[end]) {
+				end++
+			}
+			add(lexWord, i, end)
+			i = end
+		case c == '$' && g.dollarQuote:
+			tagEnd := i + 1
+			for tagEnd < len(sql) && isIdentByte(sql[tagEnd]) {
+				tagEnd++
+			}
+			if tagEnd >= len(sql) || sql[tagEnd] != '$' {
+				return invalid(i, "unexpected $")
+			}
+			tag := sql[i : tagEnd+1]
+			end := strings.Index(sql[tagEnd+1:], tag)
+			if end < 0 {
+				return invalid(i, "unterminated string")
+			}
+			add(lexString, i, tagEnd+1+end+len(tag))
+			i = tagEnd + 1 + end + len(tag)
+		case isIdentStart(c) || c >= 0x80 || c == '#' && g.hashIdent && i+1 < len(sql) && isIdentByte(sql[i+1]):
+			end := i + 1
+			for end < len(sql) && (isIdentByte(sql[end]) || sql[end] == '$' || sql[end] == '#' || sql[end] >= 0x80) {
+				end++
+			}
+			// Prefixed strings such as N'text' and E'text'
+			if end == i+1 && end < len(sql) && sql[end] == '\'' && strings.ContainsRune("NnEeXxBb", rune(c)) {
+				strEnd := quoteEnd(sql, end, g.backslashEscape || c == 'E' || c == 'e')
+				if strEnd < 0 {
+					return invalid(i, "unterminated string")
+				}
+				add(lexString, i, strEnd)
+				i = strEnd
+				continue
+			}
+			add(lexWord, i, end)
+			i = end
+		default:
+			op := ""
+			for _, o := range sqlOps {
+				if strings.HasPrefix(sql[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				if !strings.ContainsRune("()[]{},;.+-*/%=<>~!&|^:@?#", rune(c)) {
+					return invalid(i, "unexpected character "+string(rune(c)))
+				}
+				op = string(c)
+			}
+			add(lexOp, i, i+len(op))
+			i += len(op)
+		}
+	}
+	return append(tokens, lexToken{kind: lexEOF, pos: len(sql), end: len(sql)})
+}
+
+// quoteEnd returns the index after the quote closing the quoted text
+// starting at i, or -1 when it is unterminated. A doubled quote is an
+// escaped quote, as is a quote after a backslash when backslash is true.
+func quoteEnd(sql string, i int, backslash bool) int {
+	quote := sql[i]
+	for j := i + 1; j < len(sql); j++ {
+		switch {
+		case backslash && sql[j] == '\\':
+			j++
+		case sql[j] == quote:
+			if j+1 < len(sql) && sql[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// numberEnd returns the index after the number starting at i, such as 12,
+// 1.5, .5, 1e-3 or 0x1F.
+func numberEnd(sql string, i int) int {
+	j := i
+	if strings.HasPrefix(sql[i:], "0x") || strings.HasPrefix(sql[i:], "0X") {
+		j += 2
+		for j < len(sql) && strings.IndexByte("0123456789abcdefABCDEF", sql[j]) >= 0 {
+			j++
+		}
+		return j
+	}
+	for j < len(sql) && isDigit(sql[j]) {
+		j++
+	}
+	if j < len(sql) && sql[j] == '.' {
+		j++
+		for j < len(sql) && isDigit(sql[j]) {
+			j++
+		}
+	}
+	if j+1 < len(sql) && (sql[j] == 'e' || sql[j] == 'E') {
+		k := j + 1
+		if sql[k] == '+' || sql[k] == '-' {
+			k++
+		}
+		if k < len(sql) && isDigit(sql[k]) {
+			for j = k; j < len(sql) && isDigit(sql[j]); j++ {
+			}
+		}
+	}
+	return j
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentByte(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
@@ -0,0 +1,1360 @@
+package bqb
+
+import (
+	"fmt"
+)
+
+// sqlParser is a recursive descent parser of the statements checked by
+// Check. It only validates the syntax, so nothing is built from the tokens.
+type sqlParser struct {
+	g      *sqlGrammar
+	sql    string
+	tokens []lexToken
+	i      int
+	// balanced is false when the parentheses of sql don't pair up.
+	balanced bool
+}
+
+// parseFailure is panicked by the parser and recovered by parseSql.
+type parseFailure struct {
+	i   int
+	msg string
+}
+
+// intervalUnits are the units of MySQL style intervals, e.g. INTERVAL 1 DAY.
+var intervalUnits = wordSet([]string{
+	"MICROSECOND", "SECOND", "MINUTE", "HOUR", "DAY", "WEEK", "MONTH", "QUARTER", "YEAR",
+	"SECOND_MICROSECOND", "MINUTE_MICROSECOND", "MINUTE_SECOND", "HOUR_MICROSECOND",
+	"HOUR_SECOND", "HOUR_MINUTE", "DAY_MICROSECOND", "DAY_SECOND", "DAY_MINUTE",
+	"DAY_HOUR", "YEAR_MONTH",
+})
+
+// parseSql parses the statements of sql with the grammar of dialect.
+func parseSql(dialect Dialect, sql string) (err error) {
+	g := grammarFor(dialect)
+	p := &sqlParser{g: g, sql: sql, tokens: lexSql(g, sql)}
+	p.balanced = parensBalanced(p.tokens)
+	defer func() {
+		if r := recover(); r != nil {
+			f, ok := r.(parseFailure)
+			if !ok {
+				panic(r)
+			}
+			err = &SyntaxError{Dialect: dialect, SQL: sql, Near: p.near(f.i), Msg: f.msg}
+		}
+	}()
+	p.parseStatements()
+	return nil
+}
+
+func (p *sqlParser) parseStatements() {
+	statements := 0
+	for {
+		for p.acceptOp(";") {
+		}
+		if p.peek(0).kind == lexEOF {
+			break
+		}
+		p.parseStatement()
+		statements++
+		if p.peek(0).kind == lexEOF {
+			break
+		}
+		if !p.acceptOp(";") {
+			if p.isOp(0, ")") {
+				p.fail(p.i, "unbalanced parentheses, unexpected )")
+			}
+			p.unexpected()
+		}
+	}
+	if statements == 0 {
+		p.fail(p.i, "expected a statement")
+	}
+}
+
+func (p *sqlParser) parseStatement() {
+	if p.acceptKw("WITH") {
+		p.parseWith()
+	}
+	switch {
+	case p.isKw(0, "SELECT"), p.isKw(0, "VALUES"), p.isOp(0, "("):
+		p.parseQueryBody()
+	case p.isKw(0, "INSERT"), p.isKw(0, "REPLACE") && p.g.mysqlDml:
+		p.parseInsert()
+	case p.isKw(0, "UPDATE"):
+		p.parseUpdate()
+	case p.isKw(0, "DELETE"):
+		p.parseDelete()
+	case p.isKw(0, "MERGE") && p.g.merge:
+		p.parseMerge()
+	default:
+		p.fail(p.i, "expected a statement, found "+p.describe(p.i))
+	}
+}
+
+// parseQuery parses a SELECT with any common table expressions, set
+// operations, ordering and limits.
+func (p *sqlParser) parseQuery() {
+	if p.acceptKw("WITH") {
+		p.parseWith()
+	}
+	p.parseQueryBody()
+}
+
+func (p *sqlParser) parseQueryBody() {
+	p.parseSelectCore()
+	for p.peek(0).kind == lexWord && p.g.setOps[p.peek(0).upper] {
+		p.next()
+		if !p.acceptKw("ALL") {
+			p.acceptKw("DISTINCT")
+		}
+		p.parseSelectCore()
+	}
+	p.parseOrderLimit()
+}
+
+func (p *sqlParser) parseWith() {
+	p.acceptKw("RECURSIVE")
+	p.parseList(func() {
+		p.parseName("a common table expression name")
+		if p.isOp(0, "(") {
+			p.parseNameList()
+		}
+		p.expectKw("AS")
+		if p.acceptKw("NOT") {
+			p.expectKw("MATERIALIZED")
+		} else {
+			p.acceptKw("MATERIALIZED")
+		}
+		p.expectOp("(")
+		if p.isKw(0, "WITH") || p.isKw(0, "SELECT") || p.isKw(0, "VALUES") || p.isOp(0, "(") {
+			p.parseQuery()
+		} else {
+			p.parseStatement()
+		}
+		p.expectClose()
+	})
+}
+
+func (p *sqlParser) parseSelectCore() {
+	switch {
+	case p.acceptOp("("):
+		p.parseQuery()
+		p.expectClose()
+	case p.acceptKw("VALUES"):
+		p.parseRows()
+	case p.acceptKw("SELECT"):
+		p.parseSelect()
+	default:
+		p.fail(p.i, "expected SELECT, found "+p.describe(p.i))
+	}
+}
+
+func (p *sqlParser) parseSelect() {
+	if p.acceptKw("DISTINCT") {
+		if p.g.castOp && p.acceptKw("ON") {
+			p.expectOp("(")
+			p.parseList(p.parseExpr)
+			p.expectClose()
+		}
+	} else {
+		p.acceptKw("ALL")
+	}
+	if p.g.top && p.acceptKw("TOP") {
+		p.parseTop()
+	}
+
+	p.parseSelectList()
+	if p.acceptKw("INTO") {
+		p.parseQualifiedName("a table")
+	}
+	if p.acceptKw("FROM") {
+		p.parseList(p.parseTableRef)
+	}
+	p.parseWhere()
+	if p.acceptKw("GROUP") {
+		p.expectKw("BY")
+		p.parseList(p.parseExpr)
+		if p.g.mysqlDml && p.isKw(0, "WITH") && p.isKw(1, "ROLLUP") {
+			p.i += 2
+		}
+	}
+	if p.isKw(0, "HAVING") {
+		p.parseCondition()
+	}
+	if p.acceptKw("WINDOW") {
+		p.parseList(func() {
+			p.parseName("a window name")
+			p.expectKw("AS")
+			p.parseWindow()
+		})
+	}
+}
+
+func (p *sqlParser) parseSelectList() {
+	p.parseList(func() {
+		if p.acceptOp("*") {
+			return
+		}
+		p.parseExpr()
+		p.parseAlias(true)
+	})
+}
+
+// parseOrderLimit parses the ORDER BY, LIMIT, OFFSET, FETCH and locking
+// clauses following a query.
+func (p *sqlParser) parseOrderLimit() {
+	ordered := p.parseOrderBy()
+	if p.g.limit && p.acceptKw("LIMIT") {
+		if !p.acceptKw("ALL") {
+			p.parseOperand()
+		}
+		if p.g.limitComma && p.acceptOp(",") {
+			p.parseOperand()
+		} else if p.acceptKw("OFFSET") {
+			p.parseOperand()
+		}
+	}
+	if (p.g.limit || p.g.offsetFetch) && p.isKw(0, "OFFSET") {
+		if p.g.orderedFetch && !ordered {
+			p.fail(p.i, "OFFSET requires ORDER BY")
+		}
+		p.next()
+		p.parseOperand()
+		if !p.acceptKw("ROWS") {
+			p.acceptKw("ROW")
+		}
+	}
+	if p.g.offsetFetch && p.isKw(0, "FETCH") {
+		if p.g.orderedFetch && !ordered {
+			p.fail(p.i, "FETCH requires ORDER BY")
+		}
+		p.next()
+		if !p.acceptKw("FIRST") {
+			p.expectKw("NEXT")
+		}
+		if !p.isKw(0, "ROW") && !p.isKw(0, "ROWS") {
+			p.parseOperand()
+			p.acceptKw("PERCENT")
+		}
+		if !p.acceptKw("ROWS") {
+			p.expectKw("ROW")
+		}
+		if !p.acceptKw("ONLY") {
+			p.expectKw("WITH")
+			p.expectKw("TIES")
+		}
+	}
+	if !p.g.top && p.acceptKw("FOR") {
+		switch {
+		case p.acceptKw("UPDATE"), p.acceptKw("SHARE"):
+		case p.acceptKw("NO"):
+			p.expectKw("KEY")
+			p.expectKw("UPDATE")
+		default:
+			p.expectKw("KEY")
+			p.expectKw("SHARE")
+		}
+		if p.acceptKw("OF") {
+			p.parseList(func() { p.parseQualifiedName("a table") })
+		}
+		if !p.acceptKw("NOWAIT") && p.acceptKw("SKIP") {
+			p.expectKw("LOCKED")
+		}
+	}
+}
+
+func (p *sqlParser) parseOrderBy() bool {
+	if !p.acceptKw("ORDER") {
+		return false
+	}
+	p.expectKw("BY")
+	p.parseList(func() {
+		p.parseExpr()
+		if !p.acceptKw("ASC") {
+			p.acceptKw("DESC")
+		}
+		if p.acceptKw("NULLS") {
+			if !p.acceptKw("FIRST") {
+				p.expectKw("LAST")
+			}
+		}
+	})
+	return true
+}
+
+func (p *sqlParser) parseTop() {
+	if p.acceptOp("(") {
+		p.parseExpr()
+		p.expectClose()
+	} else {
+		p.parseOperand()
+	}
+	p.acceptKw("PERCENT")
+	if p.isKw(0, "WITH") && p.isKw(1, "TIES") {
+		p.i += 2
+	}
+}
+
+// parseTableRef parses a table, subquery or table function with its alias,
+// followed by any joins.
+func (p *sqlParser) parseTableRef() {
+	p.parseTablePrimary()
+	for {
+		start := p.i
+		natural := p.acceptKw("NATURAL")
+		cross := false
+		switch {
+		case p.acceptKw("JOIN"), p.g.reserved["STRAIGHT_JOIN"] && p.acceptKw("STRAIGHT_JOIN"):
+		case p.acceptKw("INNER"):
+			p.expectKw("JOIN")
+		case p.isKw(0, "LEFT"), p.isKw(0, "RIGHT"), p.isKw(0, "FULL"):
+			p.next()
+			p.acceptKw("OUTER")
+			p.expectKw("JOIN")
+		case p.g.apply && (p.isKw(0, "CROSS") || p.isKw(0, "OUTER")) && p.isKw(1, "APPLY"):
+			p.i += 2
+			p.parseTablePrimary()
+			continue
+		case p.acceptKw("CROSS"):
+			p.expectKw("JOIN")
+			cross = true
+		default:
+			if natural {
+				p.fail(p.i, "expected JOIN, found "+p.describe(p.i))
+			}
+			return
+		}
+
+		p.parseTablePrimary()
+		switch {
+		case natural || cross:
+		case p.isKw(0, "ON"):
+			p.parseCondition()
+		case p.acceptKw("USING"):
+			p.parseNameList()
+		case !p.g.mysqlDml:
+			p.fail(start, "missing ON or USING for JOIN")
+		}
+	}
+}
+
+func (p *sqlParser) parseTablePrimary() {
+	if p.g.lateral {
+		p.acceptKw("LATERAL")
+	}
+	if p.acceptOp("(") {
+		if p.startsQuery(0) {
+			p.parseQuery()
+			p.expectClose()
+			p.parseTableAlias()
+			return
+		}
+		p.parseTableRef()
+		p.expectClose()
+		return
+	}
+
+	if p.g.castOp {
+		p.acceptKw("ONLY")
+	}
+	p.parseQualifiedName("a table")
+	if p.isOp(0, "(") {
+		p.parseCallArgs("")
+	}
+	p.parseTableAlias()
+	if p.g.top && p.isKw(0, "WITH") && p.isOp(1, "(") {
+		p.i++
+		p.parseNameList()
+	}
+}
+
+func (p *sqlParser) parseTableAlias() {
+	if p.parseAlias(p.g.tableAs) && p.isOp(0, "(") {
+		p.parseNameList()
+	}
+}
+
+// parseAlias parses an optional alias, reporting whether there was one.
+func (p *sqlParser) parseAlias(allowAs bool) bool {
+	if p.isKw(0, "AS") {
+		if !allowAs {
+			p.fail(p.i, "AS is not allowed before a table alias")
+		}
+		p.next()
+		p.parseName("an alias")
+		return true
+	}
+	tok := p.peek(0)
+	if tok.kind == lexQuoted || tok.kind == lexWord && !p.g.reserved[tok.upper] {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) parseInsert() {
+	p.next()
+	if p.g.mysqlDml {
+		p.acceptKw("IGNORE")
+	}
+	if !p.acceptKw("INTO") && !p.g.mysqlDml && !p.g.top {
+		p.fail(p.i, "expected INTO, found "+p.describe(p.i))
+	}
+	p.parseQualifiedName("a table")
+	if p.g.onConflict && p.acceptKw("AS") {
+		p.parseName("an alias")
+	}
+	if p.isOp(0, "(") && !p.startsQuery(1) {
+		p.parseNameList()
+	}
+	p.parseOutput()
+
+	switch {
+	case p.acceptKw("VALUES"), p.g.mysqlDml && p.acceptKw("VALUE"):
+		p.parseRows()
+	case p.acceptKw("DEFAULT"):
+		p.expectKw("VALUES")
+	case p.g.mysqlDml && p.acceptKw("SET"):
+		p.parseAssignments()
+	case p.startsQuery(0):
+		p.parseQuery()
+	default:
+		p.fail(p.i, "expected VALUES or SELECT, found "+p.describe(p.i))
+	}
+	if p.g.mysqlDml && p.acceptKw("AS") {
+		// Row alias, e.g. VALUES (?) AS new ON DUPLICATE KEY UPDATE a = new.a
+		p.parseName("an alias")
+		if p.isOp(0, "(") {
+			p.parseNameList()
+		}
+	}
+
+	if p.g.onConflict && p.isKw(0, "ON") && p.isKw(1, "CONFLICT") {
+		p.i += 2
+		if p.acceptOp("(") {
+			p.parseList(p.parseExpr)
+			p.expectClose()
+			p.parseWhere()
+		} else if p.acceptKw("ON") {
+			p.expectKw("CONSTRAINT")
+			p.parseName("a constraint")
+		}
+		p.expectKw("DO")
+		if !p.acceptKw("NOTHING") {
+			p.expectKw("UPDATE")
+			p.expectKw("SET")
+			p.parseAssignments()
+			p.parseWhere()
+		}
+	}
+	if p.g.onDuplicate && p.isKw(0, "ON") && p.isKw(1, "DUPLICATE") {
+		p.i += 2
+		p.expectKw("KEY")
+		p.expectKw("UPDATE")
+		p.parseAssignments()
+	}
+	p.parseReturning()
+}
+
+func (p *sqlParser) parseUpdate() {
+	p.next()
+	if p.g.top && p.acceptKw("TOP") {
+		p.parseTop()
+	}
+	if p.g.mysqlDml {
+		p.acceptKw("IGNORE")
+	}
+	p.parseTableRef()
+	p.expectKw("SET")
+	p.parseAssignments()
+	p.parseOutput()
+	if !p.g.mysqlDml || p.g.castOp {
+		if p.acceptKw("FROM") {
+			p.parseList(p.parseTableRef)
+		}
+	}
+	p.parseWhere()
+	if p.g.mysqlDml {
+		p.parseOrderBy()
+		if p.acceptKw("LIMIT") {
+			p.parseOperand()
+		}
+	}
+	p.parseReturning()
+}
+
+func (p *sqlParser) parseDelete() {
+	p.next()
+	if p.g.top && p.acceptKw("TOP") {
+		p.parseTop()
+	}
+	if p.g.mysqlDml {
+		p.acceptKw("IGNORE")
+	}
+	if p.acceptKw("FROM") {
+		p.parseTablePrimary()
+	} else if p.g.mysqlDml || p.g.top {
+		// DELETE t1, t2 FROM t1 JOIN t2 ... or DELETE t FROM t ...
+		p.parseList(func() { p.parseQualifiedName("a table") })
+		if p.g.mysqlDml || p.isKw(0, "FROM") {
+			p.expectKw("FROM")
+			p.parseList(p.parseTableRef)
+		}
+	} else {
+		p.expectKw("FROM")
+	}
+	p.parseOutput()
+	if (p.g.castOp || p.g.mysqlDml) && p.acceptKw("USING") {
+		p.parseList(p.parseTableRef)
+	}
+	if p.g.top && p.acceptKw("FROM") {
+		p.parseList(p.parseTableRef)
+	}
+	p.parseWhere()
+	if p.g.mysqlDml {
+		p.parseOrderBy()
+		if p.acceptKw("LIMIT") {
+			p.parseOperand()
+		}
+	}
+	p.parseReturning()
+}
+
+func (p *sqlParser) parseMerge() {
+	p.next()
+	if p.g.top && p.acceptKw("TOP") {
+		p.parseTop()
+	}
+	p.acceptKw("INTO")
+	p.parseQualifiedName("a table")
+	p.parseAlias(p.g.tableAs)
+	p.expectKw("USING")
+	p.parseTablePrimary()
+	if !p.isKw(0, "ON") {
+		p.expectKw("ON")
+	}
+	p.parseCondition()
+
+	if !p.isKw(0, "WHEN") {
+		p.expectKw("WHEN")
+	}
+	for p.acceptKw("WHEN") {
+		p.acceptKw("NOT")
+		p.expectKw("MATCHED")
+		if p.acceptKw("BY") {
+			if !p.acceptKw("TARGET") {
+				p.expectKw("SOURCE")
+			}
+		}
+		if p.isKw(0, "AND") {
+			p.parseCondition()
+		}
+		p.expectKw("THEN")
+		switch {
+		case p.acceptKw("UPDATE"):
+			p.expectKw("SET")
+			p.parseAssignments()
+			p.parseWhere()
+			if p.acceptKw("DELETE") {
+				p.parseWhere()
+			}
+		case p.acceptKw("DELETE"):
+		case p.acceptKw("INSERT"):
+			if p.acceptKw("DEFAULT") {
+				p.expectKw("VALUES")
+				continue
+			}
+			if p.isOp(0, "(") {
+				p.parseNameList()
+			}
+			p.expectKw("VALUES")
+			p.expectOp("(")
+			p.parseList(p.parseExpr)
+			p.expectClose()
+		case p.acceptKw("DO"):
+			p.expectKw("NOTHING")
+		default:
+			p.fail(p.i, "expected UPDATE, DELETE or INSERT, found "+p.describe(p.i))
+		}
+	}
+	p.parseOutput()
+}
+
+// parseRows parses the rows of a VALUES list.
+func (p *sqlParser) parseRows() {
+	p.parseList(func() {
+		if p.g.mysqlDml {
+			p.acceptKw("ROW")
+		}
+		open := p.i
+		p.expectOp("(")
+		if p.isOp(0, ")") {
+			if !p.g.mysqlDml {
+				p.fail(open, "empty VALUES row")
+			}
+		} else {
+			p.parseList(p.parseExpr)
+		}
+		p.expectClose()
+	})
+}
+
+func (p *sqlParser) parseAssignments() {
+	p.parseList(func() {
+		if p.isOp(0, "(") {
+			p.parseNameList()
+		} else {
+			p.parseQualifiedName("a column")
+		}
+		if !p.acceptOp("=") {
+			p.fail(p.i, "expected = in assignment, found "+p.describe(p.i))
+		}
+		p.requireOperand(p.i - 1)
+		p.parseExpr()
+	})
+}
+
+func (p *sqlParser) parseOutput() {
+	if !p.g.output || !p.acceptKw("OUTPUT") {
+		return
+	}
+	p.parseSelectList()
+	if p.acceptKw("INTO") {
+		if p.peek(0).kind == lexParam {
+			p.next()
+		} else {
+			p.parseQualifiedName("a table")
+		}
+		if p.isOp(0, "(") {
+			p.parseNameList()
+		}
+	}
+}
+
+func (p *sqlParser) parseReturning() {
+	if p.g.returning && p.acceptKw("RETURNING") {
+		p.parseSelectList()
+	}
+}
+
+// parseWhere parses an optional WHERE clause.
+func (p *sqlParser) parseWhere() {
+	if p.isKw(0, "WHERE") {
+		p.parseCondition()
+	}
+}
+
+// parseCondition parses the keyword, such as WHERE or ON, and the
+// condition that must follow it.
+func (p *sqlParser) parseCondition() {
+	p.next()
+	p.requireCondition(p.i - 1)
+	p.parseExpr()
+}
+
+// requireCondition fails unless a condition follows the keyword at i.
+func (p *sqlParser) requireCondition(i int) {
+	if p.isKw(0, "AND") || p.isKw(0, "OR") {
+		p.fail(p.i, "missing condition before "+p.peek(0).upper)
+	}
+	if !p.startsExpr(0) {
+		p.fail(i, "missing condition after "+p.tokens[i].upper)
+	}
+}
+
+// requireOperand fails unless an operand follows the operator at i.
+func (p *sqlParser) requireOperand(i int) {
+	if !p.startsExpr(0) {
+		p.fail(i, "missing operand after "+p.tokens[i].text)
+	}
+}
+
+// parseExpr parses an expression, including AND, OR and NOT conditions.
+func (p *sqlParser) parseExpr() {
+	p.parseAnd()
+	for p.isKw(0, "OR") || p.g.reserved["XOR"] && p.isKw(0, "XOR") {
+		p.next()
+		p.requireCondition(p.i - 1)
+		p.parseAnd()
+	}
+}
+
+func (p *sqlParser) parseAnd() {
+	p.parseNot()
+	for p.isKw(0, "AND") {
+		p.next()
+		p.requireCondition(p.i - 1)
+		p.parseNot()
+	}
+}
+
+func (p *sqlParser) parseNot() {
+	if p.isKw(0, "NOT") {
+		p.next()
+		p.requireCondition(p.i - 1)
+		p.parseNot()
+		return
+	}
+	p.parsePredicate()
+}
+
+// parsePredicate parses an operand followed by any comparisons and
+// predicates such as IN, BETWEEN, LIKE and IS NULL.
+func (p *sqlParser) parsePredicate() {
+	p.parseOperand()
+	for {
+		not := p.isKw(0, "NOT") && (p.isKw(1, "IN") || p.isKw(1, "BETWEEN") || p.isKw(1, "LIKE") ||
+			p.isKw(1, "ILIKE") && p.g.ilike || p.isKw(1, "SIMILAR") || p.isKw(1, "REGEXP") || p.isKw(1, "RLIKE"))
+		if not {
+			p.next()
+		}
+
+		tok := p.peek(0)
+		switch {
+		case p.acceptKw("IN"):
+			p.parseInList()
+		case p.acceptKw("BETWEEN"):
+			p.acceptKw("SYMMETRIC")
+			p.requireOperand(p.i - 1)
+			p.parseOperand()
+			if !p.isKw(0, "AND") {
+				p.fail(p.i, "expected AND in BETWEEN, found "+p.describe(p.i))
+			}
+			p.next()
+			p.requireOperand(p.i - 1)
+			p.parseOperand()
+		case p.acceptKw("LIKE"), p.g.ilike && p.acceptKw("ILIKE"),
+			p.g.regexp && (p.acceptKw("REGEXP") || p.acceptKw("RLIKE")),
+			p.isKw(0, "SIMILAR") && p.isKw(1, "TO") && p.skip(2):
+			if p.isKw(0, "ANY") || p.isKw(0, "ALL") {
+				p.next()
+				p.expectOp("(")
+				p.parseList(p.parseExpr)
+				p.expectClose()
+				continue
+			}
+			p.requireOperand(p.i - 1)
+			p.parseOperand()
+			if p.acceptKw("ESCAPE") {
+				p.parseOperand()
+			}
+		case not:
+			p.unexpected()
+		case p.acceptKw("IS"):
+			p.acceptKw("NOT")
+			switch {
+			case p.acceptKw("NULL"), p.acceptKw("TRUE"), p.acceptKw("FALSE"), p.acceptKw("UNKNOWN"):
+			case p.acceptKw("DISTINCT"):
+				p.expectKw("FROM")
+				p.parseOperand()
+			default:
+				p.fail(p.i, "expected NULL after IS, found "+p.describe(p.i))
+			}
+		case p.g.castOp && (p.acceptKw("ISNULL") || p.acceptKw("NOTNULL")):
+		case tok.kind == lexOp && isComparison(tok.text, p.g):
+			p.next()
+			if (p.isKw(0, "ANY") || p.isKw(0, "ALL") || p.isKw(0, "SOME")) && p.isOp(1, "(") {
+				p.i += 2
+				if p.startsQuery(0) {
+					p.parseQuery()
+				} else {
+					p.parseExpr()
+				}
+				p.expectClose()
+				continue
+			}
+			p.requireOperand(p.i - 1)
+			p.parseOperand()
+		default:
+			return
+		}
+	}
+}
+
+func (p *sqlParser) parseInList() {
+	open := p.i
+	p.expectOp("(")
+	switch {
+	case p.isOp(0, ")"):
+		p.fail(open, "empty IN list")
+	case p.startsQuery(0):
+		p.parseQuery()
+	default:
+		p.parseList(p.parseExpr)
+	}
+	p.expectClose()
+}
+
+// parseOperand parses an operand of comparisons, which is a value joined
+// to others by arithmetic or other binary operators.
+func (p *sqlParser) parseOperand() {
+	p.parseUnary()
+	for {
+		tok := p.peek(0)
+		isOp := tok.kind == lexOp && p.g.binaryOps[tok.text] ||
+			tok.kind == lexWord && (tok.upper == "DIV" || tok.upper == "MOD") && p.g.reserved[tok.upper]
+		if !isOp {
+			return
+		}
+		p.next()
+		p.requireOperand(p.i - 1)
+		p.parseUnary()
+	}
+}
+
+func (p *sqlParser) parseUnary() {
+	if p.isOp(0, "-") || p.isOp(0, "+") || p.isOp(0, "~") || p.g.mysqlDml && p.isOp(0, "!") {
+		p.next()
+		p.requireOperand(p.i - 1)
+		p.parseUnary()
+		return
+	}
+	p.parsePrimary()
+	for {
+		switch {
+		case p.g.castOp && p.acceptOp("::"):
+			p.parseType()
+		case p.g.subscripts && p.acceptOp("["):
+			if !p.isOp(0, ":") {
+				p.parseExpr()
+			}
+			if p.acceptOp(":") && !p.isOp(0, "]") {
+				p.parseExpr()
+			}
+			p.expectOp("]")
+		case p.acceptKw("COLLATE"):
+			p.parseQualifiedName("a collation")
+		case p.isKw(0, "AT") && p.isKw(1, "TIME") && p.isKw(2, "ZONE"):
+			p.i += 3
+			p.parseUnary()
+		default:
+			return
+		}
+	}
+}
+
+func (p *sqlParser) parsePrimary() {
+	tok := p.peek(0)
+	switch tok.kind {
+	case lexNumber, lexParam:
+		p.next()
+		return
+	case lexString:
+		for p.peek(0).kind == lexString {
+			p.next()
+		}
+		return
+	case lexQuoted:
+		p.parseNameOrCall()
+		return
+	case lexOp:
+		if tok.text == "(" {
+			p.next()
+			if p.startsQuery(0) {
+				p.parseQuery()
+			} else {
+				p.parseList(p.parseExpr)
+			}
+			p.expectClose()
+			return
+		}
+	case lexWord:
+		switch tok.upper {
+		case "NULL", "TRUE", "FALSE", "DEFAULT":
+			p.next()
+			return
+		case "CASE":
+			p.parseCase()
+			return
+		case "EXISTS":
+			p.next()
+			p.expectOp("(")
+			p.parseQuery()
+			p.expectClose()
+			return
+		case "INTERVAL":
+			if k := p.peek(1).kind; k == lexString || k == lexNumber || k == lexParam || p.isOp(1, "(") {
+				p.next()
+				p.parsePrimary()
+				if w := p.peek(0); w.kind == lexWord && intervalUnits[w.upper] {
+					p.next()
+				}
+				return
+			}
+		case "DATE", "TIME", "TIMESTAMP":
+			if p.peek(1).kind == lexString {
+				p.i += 2
+				return
+			}
+		case "ARRAY":
+			if p.g.subscripts && p.isOp(1, "[") {
+				p.i += 2
+				if !p.isOp(0, "]") {
+					p.parseList(p.parseExpr)
+				}
+				p.expectOp("]")
+				return
+			}
+		}
+		if !p.g.reserved[tok.upper] {
+			p.parseNameOrCall()
+			return
+		}
+		if functionWords[tok.upper] && p.isOp(1, "(") {
+			p.next()
+			p.parseCallArgs(tok.upper)
+			return
+		}
+		if tok.upper == "AND" || tok.upper == "OR" {
+			p.fail(p.i, "missing condition before "+tok.upper)
+		}
+	}
+	p.fail(p.i, "expected an expression, found "+p.describe(p.i))
+}
+
+// parseNameOrCall parses a column such as t.id or t.*, or a function call.
+func (p *sqlParser) parseNameOrCall() {
+	name := p.peek(0).upper
+	p.next()
+	for p.acceptOp(".") {
+		if p.acceptOp("*") {
+			return
+		}
+		tok := p.peek(0)
+		if tok.kind != lexWord && tok.kind != lexQuoted {
+			p.fail(p.i, "expected a name after ., found "+p.describe(p.i))
+		}
+		name = tok.upper
+		p.next()
+	}
+	if p.isOp(0, "(") {
+		p.parseCallArgs(name)
+	}
+}
+
+// parseCallArgs parses the arguments of the function name, and any
+// WITHIN GROUP, FILTER and OVER clauses after them.
+func (p *sqlParser) parseCallArgs(name string) {
+	p.expectOp("(")
+	switch {
+	case p.isOp(0, ")"):
+	case name == "CAST" || name == "TRY_CAST":
+		p.parseExpr()
+		p.expectKw("AS")
+		p.parseType()
+	case name == "EXTRACT":
+		if tok := p.peek(0); tok.kind != lexWord && tok.kind != lexString {
+			p.fail(p.i, "expected a field, found "+p.describe(p.i))
+		}
+		p.next()
+		p.expectKw("FROM")
+		p.parseExpr()
+	case name == "POSITION" && !p.isOp(1, ","):
+		p.parseOperand()
+		p.expectKw("IN")
+		p.parseOperand()
+	case name == "TRIM":
+		if p.acceptKw("BOTH") || p.acceptKw("LEADING") || p.acceptKw("TRAILING") {
+			if !p.isKw(0, "FROM") {
+				p.parseExpr()
+			}
+			p.expectKw("FROM")
+		}
+		p.parseExpr()
+		if p.acceptKw("FROM") || p.acceptOp(",") {
+			p.parseExpr()
+		}
+	case name == "SUBSTRING" || name == "OVERLAY":
+		p.parseExpr()
+		if p.acceptKw("PLACING") {
+			p.parseExpr()
+		}
+		if p.acceptKw("FROM") {
+			p.parseExpr()
+			if p.acceptKw("FOR") {
+				p.parseExpr()
+			}
+		} else {
+			for p.acceptOp(",") {
+				p.parseExpr()
+			}
+		}
+	case name == "CONVERT" && p.g.mysqlDml:
+		p.parseExpr()
+		if p.acceptKw("USING") {
+			p.parseName("a character set")
+		} else {
+			p.expectOp(",")
+			p.parseType()
+		}
+	default:
+		if !p.acceptKw("DISTINCT") {
+			p.acceptKw("ALL")
+		}
+		if p.acceptOp("*") {
+			break
+		}
+		p.parseList(func() {
+			p.parseExpr()
+			if p.g.castOp && p.acceptOp("=>") {
+				p.parseExpr()
+			}
+		})
+		p.parseOrderBy()
+		if p.g.mysqlDml && p.acceptKw("SEPARATOR") {
+			p.parseOperand()
+		}
+	}
+	p.expectClose()
+
+	if name == "MATCH" && p.g.mysqlDml && p.acceptKw("AGAINST") {
+		p.expectOp("(")
+		p.parseOperand()
+		switch {
+		case p.acceptKw("IN"):
+			if p.acceptKw("NATURAL") {
+				p.expectKw("LANGUAGE")
+			} else {
+				p.expectKw("BOOLEAN")
+			}
+			p.expectKw("MODE")
+			if p.acceptKw("WITH") {
+				p.expectKw("QUERY")
+				p.expectKw("EXPANSION")
+			}
+		case p.acceptKw("WITH"):
+			p.expectKw("QUERY")
+			p.expectKw("EXPANSION")
+		}
+		p.expectClose()
+	}
+	if p.isKw(0, "WITHIN") && p.isKw(1, "GROUP") {
+		p.i += 2
+		p.expectOp("(")
+		if !p.parseOrderBy() {
+			p.expectKw("ORDER")
+		}
+		p.expectClose()
+	}
+	if p.isKw(0, "FILTER") && p.isOp(1, "(") {
+		p.i += 2
+		if !p.isKw(0, "WHERE") {
+			p.expectKw("WHERE")
+		}
+		p.parseCondition()
+		p.expectClose()
+	}
+	if (p.isKw(0, "IGNORE") || p.isKw(0, "RESPECT")) && p.isKw(1, "NULLS") {
+		p.i += 2
+	}
+	if p.acceptKw("OVER") {
+		if p.isOp(0, "(") {
+			p.parseWindow()
+		} else {
+			p.parseName("a window name")
+		}
+	}
+}
+
+func (p *sqlParser) parseWindow() {
+	p.expectOp("(")
+	if tok := p.peek(0); tok.kind == lexWord && !p.g.reserved[tok.upper] &&
+		tok.upper != "PARTITION" && tok.upper != "ROWS" && tok.upper != "RANGE" && tok.upper != "GROUPS" {
+		p.next()
+	}
+	if p.acceptKw("PARTITION") {
+		p.expectKw("BY")
+		p.parseList(p.parseExpr)
+	}
+	p.parseOrderBy()
+	if p.acceptKw("ROWS") || p.acceptKw("RANGE") || p.acceptKw("GROUPS") {
+		if p.acceptKw("BETWEEN") {
+			p.parseFrameBound()
+			p.expectKw("AND")
+			p.parseFrameBound()
+		} else {
+			p.parseFrameBound()
+		}
+	}
+	p.expectClose()
+}
+
+func (p *sqlParser) parseFrameBound() {
+	switch {
+	case p.acceptKw("CURRENT"):
+		p.expectKw("ROW")
+		return
+	case p.acceptKw("UNBOUNDED"):
+	default:
+		p.parseOperand()
+	}
+	if !p.acceptKw("PRECEDING") {
+		p.expectKw("FOLLOWING")
+	}
+}
+
+func (p *sqlParser) parseCase() {
+	p.next()
+	if !p.isKw(0, "WHEN") {
+		p.parseExpr()
+	}
+	if !p.isKw(0, "WHEN") {
+		p.expectKw("WHEN")
+	}
+	for p.acceptKw("WHEN") {
+		p.requireCondition(p.i - 1)
+		p.parseExpr()
+		p.expectKw("THEN")
+		p.parseExpr()
+	}
+	if p.acceptKw("ELSE") {
+		p.parseExpr()
+	}
+	p.expectKw("END")
+}
+
+// parseType parses a type such as INT, VARCHAR(10), DOUBLE PRECISION,
+// TIMESTAMP(3) WITH TIME ZONE or TEXT[].
+func (p *sqlParser) parseType() {
+	tok := p.peek(0)
+	if tok.kind != lexWord && tok.kind != lexQuoted {
+		p.fail(p.i, "expected a type, found "+p.describe(p.i))
+	}
+	p.parseQualifiedName("a type")
+	switch {
+	case tok.upper == "DOUBLE":
+		p.acceptKw("PRECISION")
+	case tok.upper == "CHARACTER" || tok.upper == "CHAR" || tok.upper == "NATIONAL":
+		p.acceptKw("VARYING")
+	case tok.upper == "SIGNED" || tok.upper == "UNSIGNED":
+		if !p.acceptKw("INTEGER") {
+			p.acceptKw("INT")
+		}
+	}
+	if p.acceptOp("(") {
+		p.parseList(p.parseExpr)
+		p.expectClose()
+	}
+	if (tok.upper == "TIMESTAMP" || tok.upper == "TIME") && (p.isKw(0, "WITH") || p.isKw(0, "WITHOUT")) &&
+		p.isKw(1, "TIME") && p.isKw(2, "ZONE") {
+		p.i += 3
+	}
+	for p.g.subscripts && p.acceptOp("[") {
+		if p.peek(0).kind == lexNumber {
+			p.next()
+		}
+		p.expectOp("]")
+	}
+}
+
+// parseList parses items separated by commas, failing on a missing item.
+func (p *sqlParser) parseList(item func()) {
+	for {
+		if p.isOp(0, ",") {
+			if p.i > 0 && p.tokens[p.i-1].text == "," {
+				p.fail(p.i, "extra comma")
+			}
+			p.fail(p.i, "leading comma")
+		}
+		item()
+		if !p.acceptOp(",") {
+			return
+		}
+		if p.isOp(0, ",") {
+			p.fail(p.i, "extra comma")
+		}
+		if tok := p.peek(0); tok.kind == lexEOF || tok.kind == lexOp && (tok.text == ")" || tok.text == ";") ||
+			tok.kind == lexWord && p.g.reserved[tok.upper] && !p.startsExpr(0) {
+			p.fail(p.i-1, "trailing comma")
+		}
+	}
+}
+
+// parseNameList parses a parenthesized list of names, e.g. `(a, b)`.
+func (p *sqlParser) parseNameList() {
+	open := p.i
+	p.expectOp("(")
+	if p.isOp(0, ")") {
+		p.fail(open, "empty list of names")
+	}
+	p.parseList(func() { p.parseName("a name") })
+	p.expectClose()
+}
+
+func (p *sqlParser) parseQualifiedName(what string) {
+	p.parseName(what)
+	for p.acceptOp(".") {
+		p.parseName(what)
+	}
+}
+
+func (p *sqlParser) parseName(what string) {
+	tok := p.peek(0)
+	if tok.kind == lexQuoted || tok.kind == lexWord && !p.g.reserved[tok.upper] {
+		p.next()
+		return
+	}
+	if tok.kind == lexParam && p.g.top {
+		// MSSQL table variables, e.g. @t
+		p.next()
+		return
+	}
+	p.fail(p.i, fmt.Sprintf("expected %v, found %v", what, p.describe(p.i)))
+}
+
+// startsExpr reports whether the token at offset n can start an expression.
+func (p *sqlParser) startsExpr(n int) bool {
+	tok := p.peek(n)
+	switch tok.kind {
+	case lexNumber, lexParam, lexString, lexQuoted:
+		return true
+	case lexOp:
+		return tok.text == "(" || tok.text == "-" || tok.text == "+" || tok.text == "~" ||
+			tok.text == "!" && p.g.mysqlDml
+	case lexWord:
+		switch tok.upper {
+		case "NOT", "CASE", "EXISTS", "NULL", "TRUE", "FALSE", "DEFAULT":
+			return true
+		}
+		return !p.g.reserved[tok.upper] || functionWords[tok.upper] && p.isOp(n+1, "(")
+	}
+	return false
+}
+
+// startsQuery reports whether the token at offset n starts a query.
+func (p *sqlParser) startsQuery(n int) bool {
+	if p.isKw(n, "SELECT") || p.isKw(n, "WITH") || p.isKw(n, "VALUES") {
+		return true
+	}
+	// A parenthesized query, e.g. ((SELECT 1) UNION (SELECT 2))
+	for p.isOp(n, "(") {
+		n++
+	}
+	return n > 0 && p.isKw(n, "SELECT")
+}
+
+func isComparison(op string, g *sqlGrammar) bool {
+	switch op {
+	case "=", "<>", "!=", "<", ">", "<=", ">=":
+		return true
+	case "<=>":
+		return g.mysqlDml
+	}
+	return false
+}
+
+func (p *sqlParser) peek(n int) lexToken {
+	if p.i+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.i+n]
+}
+
+func (p *sqlParser) next() {
+	tok := p.peek(0)
+	if tok.kind == lexInvalid {
+		p.fail(p.i, tok.msg)
+	}
+	if tok.kind != lexEOF {
+		p.i++
+	}
+}
+
+// skip advances n tokens and returns true, for use within conditions.
+func (p *sqlParser) skip(n int) bool {
+	p.i += n
+	return true
+}
+
+func (p *sqlParser) isKw(n int, kw string) bool {
+	tok := p.peek(n)
+	return tok.kind == lexWord && tok.upper == kw
+}
+
+func (p *sqlParser) isOp(n int, op string) bool {
+	tok := p.peek(n)
+	return tok.kind == lexOp && tok.text == op
+}
+
+func (p *sqlParser) acceptKw(kw string) bool {
+	if p.isKw(0, kw) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) acceptOp(op string) bool {
+	if p.isOp(0, op) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) expectKw(kw string) {
+	if !p.acceptKw(kw) {
+		p.fail(p.i, fmt.Sprintf("expected %v, found %v", kw, p.describe(p.i)))
+	}
+}
+
+func (p *sqlParser) expectOp(op string) {
+	if !p.acceptOp(op) {
+		p.fail(p.i, fmt.Sprintf("expected %v, found %v", op, p.describe(p.i)))
+	}
+}
+
+// expectClose expects the `)` closing a parenthesis.
+func (p *sqlParser) expectClose() {
+	if !p.acceptOp(")") {
+		if !p.balanced {
+			p.fail(p.i, "unbalanced parentheses, expected ), found "+p.describe(p.i))
+		}
+		p.fail(p.i, "expected ), found "+p.describe(p.i))
+	}
+}
+
+func parensBalanced(tokens []lexToken) bool {
+	depth := 0
+	for _, tok := range tokens {
+		if tok.kind == lexOp && tok.text == "(" {
+			depth++
+		} else if tok.kind == lexOp && tok.text == ")" {
+			if depth--; depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// unexpected fails at the current token.
+func (p *sqlParser) unexpected() {
+	p.fail(p.i, "unexpected "+p.describe(p.i))
+}
+
+func (p *sqlParser) fail(i int, msg string) {
+	if tok := p.tokens[min(i, len(p.tokens)-1)]; tok.kind == lexInvalid {
+		msg = tok.msg
+	}
+	panic(parseFailure{i: i, msg: msg})
+}
+
+func (p *sqlParser) describe(i int) string {
+	switch tok := p.tokens[min(i, len(p.tokens)-1)]; tok.kind {
+	case lexEOF:
+		return "end of input"
+	case lexInvalid:
+		return tok.msg
+	default:
+		return tok.text
+	}
+}
+
+// near returns the text of the tokens surrounding token i.
+func (p *sqlParser) near(i int) string {
+	i = min(i, len(p.tokens)-1)
+	start, end := max(i-3, 0), min(i+3, len(p.tokens))
+	return p.sql[p.tokens[start].pos:p.tokens[end-1].end]
+}
@@ -0,0 +1,219 @@
+package bqb
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	templateNameRe       = regexp.MustCompile(`^\s*--\s*name:\s*(\S+)\s*$`)
+	templateAnnotationRe = regexp.MustCompile(`^\s*--\s*([A-Za-z_]\w*):\s*(.*?)\s*$`)
+)
+
+// Template is a named block of SQL loaded by LoadTemplates.
+type Template struct {
+	Name string
+	// SQL is the text of the block without line comments, with any
+	// :name placeholders replaced by ? and any ? in quoted text or block
+	// comments escaped as ??.
+	SQL string
+	// Params lists the :name placeholders in the order they appear,
+	// and is nil when the block uses ? placeholders.
+	Params []string
+	// Annotations holds the `-- key: value` comment lines that directly
+	// follow the `-- name:` line.
+	Annotations map[string][]string
+}
+
+// LoadTemplates parses every .sql file in fsys into templates keyed by
+// name. Each block of SQL starts with a `-- name: <name>` line and may use
+// either ? or :name placeholders, e.g.
+//
+//	-- name: active_users
+//	SELECT * FROM users WHERE active AND created > :since
+func LoadTemplates(fsys fs.FS) (map[string]*Template, error) {
+	templates := map[string]*Template{}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(p) != ".sql" {
+			return err
+		}
+
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		parsed, err := ParseTemplates(string(data))
+		if err != nil {
+			return fmt.Errorf("%v: %w", p, err)
+		}
+		for _, t := range parsed {
+			if _, ok := templates[t.Name]; ok {
+				return fmt.Errorf("%v: duplicate template name: %v", p, t.Name)
+			}
+			templates[t.Name] = t
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// ParseTemplates parses the named blocks of SQL in text. See LoadTemplates.
+func ParseTemplates(text string) ([]*Template, error) {
+	var templates []*Template
+	var current *Template
+	var body []string
+	inHeader := false
+
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+		sql, params, err := parseNamedParams(strings.Join(body, "\n"))
+		if err != nil {
+			return fmt.Errorf("template %v: %w", current.Name, err)
+		}
+		var lines []string
+		for _, line := range strings.Split(sql, "\n") {
+			if line = strings.TrimRightFunc(line, unicode.IsSpace); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		sql = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(strings.Join(lines, "\n")), ";"))
+		if sql == "" {
+			return fmt.Errorf("empty template: %v", current.Name)
+		}
+		current.SQL, current.Params = sql, params
+		templates = append(templates, current)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := templateNameRe.FindStringSubmatch(line); m != nil {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			current = &Template{Name: m[1], Annotations: map[string][]string{}}
+			body = nil
+			inHeader = true
+			continue
+		}
+
+		if inHeader {
+			if m := templateAnnotationRe.FindStringSubmatch(line); m != nil {
+				current.Annotations[m[1]] = append(current.Annotations[m[1]], m[2])
+				continue
+			}
+			inHeader = false
+		}
+
+		if current == nil {
+			if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return nil, fmt.Errorf("SQL outside of a named block: %v", trimmed)
+			}
+			continue
+		}
+		body = append(body, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// Query returns a new Query of the template bound to args, which are given
+// in the order of the placeholders.
+func (t *Template) Query(args ...any) *Query {
+	return New(t.SQL, args...)
+}
+
+// Named returns a new Query of the template with each :name placeholder
+// bound to args[name].
+func (t *Template) Named(args map[string]any) *Query {
+	values := make([]any, len(t.Params))
+	for i, name := range t.Params {
+		v, ok := args[name]
+		if !ok {
+			return errQuery(fmt.Errorf("missing template param: %v", name))
+		}
+		values[i] = v
+	}
+	return New(t.SQL, values...)
+}
+
+// parseNamedParams replaces the :name placeholders of sql outside of
+// quotes and comments with ?, returning the names in order. Line comments
+// are removed, so that SQL joined to the template is not commented out, and
+// any ? in quoted text or block comments is escaped as ??.
+func parseNamedParams(sql string) (string, []string, error) {
+	var builder strings.Builder
+	var names []string
+	positional := false
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(sql[i+1:], c) + i + 2
+			if end < i+2 {
+				end = len(sql)
+			}
+			builder.WriteString(strings.ReplaceAll(sql[i:end], "?", "??"))
+			i = end - 1
+			continue
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			i += end - 1
+			continue
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/") + i + 4
+			if end < i+4 {
+				end = len(sql)
+			}
+			builder.WriteString(strings.ReplaceAll(sql[i:end], "?", "??"))
+			i = end - 1
+			continue
+		case c == '?':
+			if strings.HasPrefix(sql[i:], "??") {
+				builder.WriteString("??")
+				i++
+				continue
+			}
+			positional = true
+		case c == ':' && (i == 0 || sql[i-1] != ':') && i+1 < len(sql) && isIdentStart(sql[i+1]):
+			j := i + 1
+			for j < len(sql) && isWordByte(sql[j]) && sql[j] != '.' {
+				j++
+			}
+			names = append(names, sql[i+1:j])
+			builder.WriteString("?")
+			i = j - 1
+			continue
+		}
+		builder.WriteByte(c)
+	}
+
+	if positional && len(names) > 0 {
+		return "", nil, fmt.Errorf("mixed ? and :name placeholders")
+	}
+	return builder.String(), names, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,134 @@
+package bqb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const usersSql = `-- Queries for the users table
+
+-- name: active_users
+-- description: users active since a date
+SELECT * FROM users
+WHERE active AND created > :since AND id IN (:ids) AND name::text <> ':skip' -- :not_a_param
+	AND (id = :ids OR data ?? 'key');
+
+-- name: user_by_id
+SELECT * FROM users WHERE id = ?
+`
+
+func TestLoadTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"queries/users.sql":  {Data: []byte(usersSql)},
+		"queries/orders.sql": {Data: []byte("-- name: orders\nSELECT * FROM orders\n")},
+		"queries/README.md":  {Data: []byte("not sql")},
+	}
+
+	templates, err := LoadTemplates(fsys)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if len(templates) != 3 {
+		t.Errorf("want 3 templates, got %v", len(templates))
+	}
+
+	active := templates["active_users"]
+	if !reflect.DeepEqual(active.Params, []string{"since", "ids", "ids"}) {
+		t.Errorf("unexpected params: %v", active.Params)
+	}
+	if !reflect.DeepEqual(active.Annotations["description"], []string{"users active since a date"}) {
+		t.Errorf("unexpected annotations: %v", active.Annotations)
+	}
+
+	q := active.Named(map[string]any{"since": "2024-01-01", "ids": []int{1, 2}}).Space("LIMIT ?", 10)
+	sql, params, err := q.ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	want := "SELECT * FROM users\nWHERE active AND created > $1 AND id IN ($2,$3) AND name::text <> ':skip'\n" +
+		"\tAND (id = $4,$5 OR data ? 'key') LIMIT $6"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{"2024-01-01", 1, 2, 1, 2, 10}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	sql, _ = templates["user_by_id"].Query(7).ToRaw()
+	if sql != "SELECT * FROM users WHERE id = 7" {
+		t.Errorf("got: %q", sql)
+	}
+
+	_, _, err = active.Named(map[string]any{"since": 1}).ToSql()
+	if err == nil || !strings.Contains(err.Error(), "missing template param: ids") {
+		t.Errorf("expected missing param error, got: %v", err)
+	}
+}
+
+func TestLoadTemplates_Errors(t *testing.T) {
+	tests := map[string]string{
+		"SELECT 1":                  "outside of a named block",
+		"-- name: a\n\n;":           "empty template",
+		"-- name: a\nSELECT ? + :b": "mixed",
+		"-- name: a\nSELECT 1\n-- name: a\nSELECT 2\n": "duplicate",
+		"-- name: a\nSELECT 'unterminated":             "",
+	}
+	for text, wantErr := range tests {
+		_, err := LoadTemplates(fstest.MapFS{"a.sql": {Data: []byte(text)}})
+		if wantErr == "" {
+			if err != nil {
+				t.Errorf("got unexpected error for %q: %v", text, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("expected %q error for %q, got: %v", wantErr, text, err)
+		}
+	}
+
+	_, err := LoadTemplates(fstest.MapFS{
+		"a.sql": {Data: []byte("-- name: a\nSELECT 1")},
+		"b.sql": {Data: []byte("-- name: a\nSELECT 1")},
+	})
+	if err == nil || !strings.Contains(err.Error(), "duplicate template name") {
+		t.Errorf("expected duplicate error, got: %v", err)
+	}
+}
+
+func TestParseTemplates_Comments(t *testing.T) {
+	templates, err := ParseTemplates("-- name: a\nSELECT * FROM t -- why?\n/* which? */ WHERE id = :id\n-- all done;\n\n;")
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if want := "SELECT * FROM t\n/* which?? */ WHERE id = ?"; templates[0].SQL != want {
+		t.Errorf("\n got: %q\nwant: %q", templates[0].SQL, want)
+	}
+
+	sql, params, err := templates[0].Named(map[string]any{"id": 1}).And("b = ?", 2).ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	if want := "SELECT * FROM t\n/* which? */ WHERE id = $1 AND b = $2"; sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{1, 2}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	templates, _ = ParseTemplates("-- name: q\nSELECT * FROM t WHERE a = :a AND b = 'x?' AND \"c?\" = 1\n")
+	sql, params, err = templates[0].Named(map[string]any{"a": 1}).ToPgsql()
+	if want := `SELECT * FROM t WHERE a = $1 AND b = 'x?' AND "c?" = 1`; err != nil || sql != want {
+		t.Errorf("\n got: %q %v\nwant: %q", sql, err, want)
+	}
+	if !reflect.DeepEqual(params, []any{1}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	templates, _ = ParseTemplates("-- name: b\nSELECT * FROM t WHERE a = 1 -- active only\n")
+	sql, _, _ = templates[0].Query().And("b = ?", 2).ToSql()
+	if want := "SELECT * FROM t WHERE a = 1 AND b = ?"; sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+}
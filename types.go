@@ -1,5 +1,13 @@
 package bqb
 
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
 // Dialect holds the Query dialect
 type Dialect string
 
@@ -8,14 +16,39 @@ const (
 	PGSQL Dialect = "postgres"
 	// MYSQL MySQL dialect
 	MYSQL Dialect = "mysql"
+	// MARIADB MariaDB dialect
+	MARIADB Dialect = "mariadb"
 	// RAW dialect uses no parameter conversion
 	RAW Dialect = "raw"
 	// SQL generic dialect
 	SQL Dialect = "sql"
+	// MSSQL SQL Server dialect
+	MSSQL Dialect = "sqlserver"
+	// ORACLE Oracle dialect
+	ORACLE Dialect = "oracle"
 
-	paramPh = "{{xX_PARAM_Xx}}"
+	paramPh  = "{{xX_PARAM_Xx}}"
+	outputPh = "{{xX_OUTPUT_Xx}}"
 )
 
+// ByDialect is an argument whose rendering is chosen by the Dialect the
+// Query is compiled for, e.g. `NOW()` vs `CURRENT_TIMESTAMP`. MARIADB falls
+// back to MYSQL, and any dialect without an entry falls back to SQL.
+type ByDialect map[Dialect]*Query
+
+func (b ByDialect) render(dialect Dialect) (*Query, error) {
+	if q, ok := b[dialect]; ok {
+		return q, nil
+	}
+	if q, ok := b[MYSQL]; ok && dialect == MARIADB {
+		return q, nil
+	}
+	if q, ok := b[SQL]; ok {
+		return q, nil
+	}
+	return nil, fmt.Errorf("no ByDialect query for dialect: %v", dialect)
+}
+
 // Embedded is a string type that is directly embedded into the query.
 // Note: Like Embedder, this is not to be used for untrusted input.
 type Embedded string
@@ -47,3 +80,48 @@ func ToFolded[T any](slice []T) Folded {
 	}
 	return valueArr
 }
+
+// PgArray is a slice that binds as a single Postgres array parameter, e.g.
+// `WHERE id = ANY(?)`. It implements driver.Valuer, producing the array
+// literal syntax (`{a,"b c",NULL}`) so no driver specific types are needed.
+type PgArray[T any] []T
+
+// Value implements driver.Valuer by returning the Postgres array literal.
+func (a PgArray[T]) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	var builder strings.Builder
+	builder.WriteString("{")
+	for i, v := range a {
+		if i > 0 {
+			builder.WriteString(",")
+		}
+		elem, err := pgArrayElem(v)
+		if err != nil {
+			return nil, err
+		}
+		builder.WriteString(elem)
+	}
+	builder.WriteString("}")
+	return builder.String(), nil
+}
+
+// fragment is an argument whose text depends on the Dialect, so it is only
+// rendered once the Query is compiled.
+type fragment interface {
+	render(dialect Dialect) (*Query, error)
+}
+
+// Execer executes compiled queries. It is implemented by *sql.DB, *sql.Tx
+// and *sql.Conn, and by bqbtest.Recorder for tests.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Queryer runs compiled queries that return rows. It is implemented by
+// *sql.DB, *sql.Tx and *sql.Conn, and by bqbtest.Recorder for tests.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
@@ -1,6 +1,7 @@
 package bqb
 
 import (
+	dbsql "database/sql"
 	"reflect"
 	"strings"
 	"testing"
@@ -98,3 +99,110 @@ func TestEmbedded(t *testing.T) {
 	}
 
 }
+
+func TestPgArray(t *testing.T) {
+	s := "p"
+	var sn *string
+	q := New(
+		"a = ANY(?) AND b = ANY(?) AND c = ANY(?) AND d = ?",
+		PgArray[string]{"a", "b c", "", "null", `q"\`},
+		PgArray[int]{1, 2, 3},
+		PgArray[*string]{&s, sn},
+		PgArray[bool](nil),
+	)
+	sql, params, err := q.ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+
+	want := "a = ANY($1) AND b = ANY($2) AND c = ANY($3) AND d = $4"
+	if sql != want {
+		t.Errorf("\n got:%v\nwant:%v", sql, want)
+	}
+
+	wantParams := []any{`{a,"b c","","null","q\"\\"}`, "{1,2,3}", "{p,NULL}", nil}
+	if !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("\n got:%v\nwant:%v", params, wantParams)
+	}
+
+	sql, err = New("? ?", PgArray[any]{true, 1.5, []byte{1}, valuer{"v"}}, PgArray[string]{"it's"}).ToRaw()
+	if err != nil {
+		t.Errorf("got error from ToRaw(): %v", err)
+	}
+	want = `'{t,1.5,"\\x01",v}' '{it''s}'`
+	if sql != want {
+		t.Errorf("\n got:%v\nwant:%v", sql, want)
+	}
+
+	_, _, err = New("?", PgArray[valuer]{nil}).ToSql()
+	if err == nil {
+		t.Errorf("expected error from element Value()")
+	}
+
+	_, params, err = New("?", PgArray[*dbsql.NullString]{nil, {String: "x", Valid: true}}).ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	if !reflect.DeepEqual(params, []any{"{NULL,x}"}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+}
+
+func TestByDialect(t *testing.T) {
+	like := ByDialect{
+		PGSQL: New("name ILIKE ?", "a%"),
+		MYSQL: New("name LIKE ? COLLATE utf8mb4_general_ci", "a%"),
+		SQL:   New("LOWER(name) LIKE LOWER(?)", "a%"),
+	}
+	q := New("SELECT * FROM users WHERE ? AND created < ?", like, ByDialect{
+		PGSQL: New("NOW()"),
+		SQL:   New("CURRENT_TIMESTAMP"),
+	})
+
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{PGSQL, "SELECT * FROM users WHERE name ILIKE $1 AND created < NOW()"},
+		{MYSQL, "SELECT * FROM users WHERE name LIKE ? COLLATE utf8mb4_general_ci AND created < CURRENT_TIMESTAMP"},
+		{MARIADB, "SELECT * FROM users WHERE name LIKE ? COLLATE utf8mb4_general_ci AND created < CURRENT_TIMESTAMP"},
+		{MSSQL, "SELECT * FROM users WHERE LOWER(name) LIKE LOWER(@p1) AND created < CURRENT_TIMESTAMP"},
+	}
+	for _, tt := range tests {
+		sql, params, err := q.ToDialect(tt.dialect)
+		if err != nil {
+			t.Errorf("%v: got error: %v", tt.dialect, err)
+		}
+		if sql != tt.want {
+			t.Errorf("%v:\n got:%v\nwant:%v", tt.dialect, sql, tt.want)
+		}
+		if !reflect.DeepEqual(params, []any{"a%"}) {
+			t.Errorf("%v: unexpected params: %v", tt.dialect, params)
+		}
+	}
+
+	_, _, err := New("?", ByDialect{PGSQL: New("NOW()")}).ToMysql()
+	if err == nil || !strings.Contains(err.Error(), "no ByDialect query") {
+		t.Errorf("expected missing dialect error, got: %v", err)
+	}
+}
+
+func TestByDialect_Secret(t *testing.T) {
+	q := New("SELECT ?, ?", Secret(ByDialect{PGSQL: New("NOW()"), SQL: New("CURRENT_TIMESTAMP")}),
+		Secret(ByDialect{SQL: New("LOWER(?)", "ssn")}))
+
+	sql, params, err := q.ToPgsql()
+	if err != nil || sql != "SELECT NOW(), LOWER($1)" || !reflect.DeepEqual(params, []any{"ssn"}) {
+		t.Errorf("unexpected result: %q %v %v", sql, params, err)
+	}
+
+	raw, err := q.ToRaw()
+	if err != nil || raw != "SELECT CURRENT_TIMESTAMP, LOWER([REDACTED])" {
+		t.Errorf("unexpected raw: %q %v", raw, err)
+	}
+
+	sql, params, err = New("SELECT * FROM t WHERE ?", Secret(New("? AND a = ?", JsonHasKey("c", "k"), 1))).ToPgsql()
+	if err != nil || sql != "SELECT * FROM t WHERE c ? $1 AND a = $2" || !reflect.DeepEqual(params, []any{"k", 1}) {
+		t.Errorf("unexpected result: %q %v %v", sql, params, err)
+	}
+}
@@ -0,0 +1,63 @@
+package bqb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type upsertClause struct {
+	conflictCols []string
+	updateCols   []string
+}
+
+func (u upsertClause) render(dialect Dialect) (*Query, error) {
+	switch dialect {
+	case MYSQL, MARIADB:
+		set := Q()
+		for _, col := range u.updateCols {
+			set.Comma(col + " = VALUES(" + col + ")")
+		}
+		if set.Empty() && len(u.conflictCols) > 0 {
+			// MySQL has no DO NOTHING, so update a column to itself.
+			set.Comma(u.conflictCols[0] + " = " + u.conflictCols[0])
+		}
+		return New("ON DUPLICATE KEY UPDATE ?", set), nil
+	case MSSQL, ORACLE:
+		return nil, fmt.Errorf("upsert is not supported for dialect: %v", dialect)
+	default:
+		conflict := "ON CONFLICT (" + strings.Join(u.conflictCols, ",") + ")"
+		if len(u.updateCols) == 0 {
+			return New(conflict + " DO NOTHING"), nil
+		}
+		set := Q()
+		for _, col := range u.updateCols {
+			set.Comma(col + " = EXCLUDED." + col)
+		}
+		return New(conflict+" DO UPDATE SET ?", set), nil
+	}
+}
+
+// Upsert returns an INSERT query for table that updates updateCols when a
+// row conflicts on conflictCols, rendered as `ON CONFLICT ... DO UPDATE` or
+// `ON DUPLICATE KEY UPDATE` for MySQL. The values are bound in rows of
+// len(cols), so multiple rows can be inserted at once.
+// Note: Like Embedded, table and column names are not to be used for
+// untrusted input.
+func Upsert(table string, cols, conflictCols, updateCols []string, values ...any) *Query {
+	if len(cols) == 0 || len(conflictCols) == 0 {
+		return errQuery(errors.New("upsert requires columns and conflict columns"))
+	}
+	if len(values) == 0 || len(values)%len(cols) != 0 {
+		return errQuery(fmt.Errorf("upsert requires rows of %d values, got %d", len(cols), len(values)))
+	}
+
+	row := "(" + strings.TrimSuffix(strings.Repeat("?,", len(cols)), ",") + ")"
+	rows := Q()
+	for i := 0; i < len(values); i += len(cols) {
+		rows.Comma(row, values[i:i+len(cols)]...)
+	}
+
+	return New("INSERT INTO "+table+" ("+strings.Join(cols, ",")+") VALUES ?", rows).
+		Space("?", upsertClause{conflictCols: conflictCols, updateCols: updateCols})
+}
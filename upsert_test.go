@@ -0,0 +1,65 @@
+package bqb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpsert(t *testing.T) {
+	q := Upsert("users", []string{"id", "name", "email"}, []string{"id"}, []string{"name", "email"},
+		1, "a", "a@x", 2, "b", "b@x")
+
+	sql, params, err := q.ToPgsql()
+	if err != nil {
+		t.Errorf("got error: %v", err)
+	}
+	want := "INSERT INTO users (id,name,email) VALUES ($1,$2,$3),($4,$5,$6) " +
+		"ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name,email = EXCLUDED.email"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+	if !reflect.DeepEqual(params, []any{1, "a", "a@x", 2, "b", "b@x"}) {
+		t.Errorf("unexpected params: %v", params)
+	}
+
+	sql, _, _ = q.ToMysql()
+	want = "INSERT INTO users (id,name,email) VALUES (?,?,?),(?,?,?) " +
+		"ON DUPLICATE KEY UPDATE name = VALUES(name),email = VALUES(email)"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+
+	_, _, err = q.ToDialect(MSSQL)
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("expected unsupported dialect error, got: %v", err)
+	}
+}
+
+func TestUpsert_NoUpdate(t *testing.T) {
+	q := Upsert("tags", []string{"name"}, []string{"name"}, nil, "go")
+
+	sql, _ := q.ToRaw()
+	want := "INSERT INTO tags (name) VALUES ('go') ON CONFLICT (name) DO NOTHING"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+
+	sql, _, _ = q.ToMysql()
+	want = "INSERT INTO tags (name) VALUES (?) ON DUPLICATE KEY UPDATE name = name"
+	if sql != want {
+		t.Errorf("\n got: %q\nwant: %q", sql, want)
+	}
+}
+
+func TestUpsert_Errors(t *testing.T) {
+	_, _, err := Upsert("t", []string{"a", "b"}, []string{"a"}, nil, 1).ToSql()
+	if err == nil || !strings.Contains(err.Error(), "rows of 2 values") {
+		t.Errorf("expected row size error, got: %v", err)
+	}
+
+	_, _, err = Upsert("t", []string{"a"}, nil, nil, 1).ToSql()
+	if err == nil || !strings.Contains(err.Error(), "conflict columns") {
+		t.Errorf("expected conflict columns error, got: %v", err)
+	}
+}
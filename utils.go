@@ -2,10 +2,12 @@ package bqb
 
 import (
 	"database/sql/driver"
-	"encoding/json"
+	"encoding/hex"
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func dialectReplace(dialect Dialect, sql string, params []any) (string, error) {
@@ -25,29 +27,109 @@ func dialectReplace(dialect Dialect, sql string, params []any) (string, error) {
 			sql = strings.Replace(sql, paramPh, p, 1)
 		}
 		return sql, nil
-	case MYSQL, SQL:
+	case MYSQL, MARIADB, SQL:
 		return strings.ReplaceAll(sql, paramPh, questionMark), nil
 	case PGSQL:
-		sql = strings.ReplaceAll(sql, doubleQuestionMarkDelimiter, questionMark)
-		parts := strings.Split(sql, paramPh)
-		var builder strings.Builder
-		for i := range params {
-			_, _ = builder.WriteString(parts[i] + "$" + strconv.Itoa(i+1))
-		}
-		builder.WriteString(parts[len(parts)-1])
-		return builder.String(), nil
+		return numberedReplace(sql, params, "$"), nil
+	case MSSQL:
+		return numberedReplace(sql, params, "@p"), nil
+	case ORACLE:
+		return numberedReplace(sql, params, ":"), nil
 	default:
 		// No replacement defined for dialect
 		return sql, nil
 	}
 }
 
+func numberedReplace(sql string, params []any, prefix string) string {
+	sql = strings.ReplaceAll(sql, "??", "?")
+	parts := strings.Split(sql, paramPh)
+	var builder strings.Builder
+	for i := range params {
+		_, _ = builder.WriteString(parts[i] + prefix + strconv.Itoa(i+1))
+	}
+	builder.WriteString(parts[len(parts)-1])
+	return builder.String()
+}
+
+// resolveFragments renders each fragment in params for dialect, replacing
+// its placeholder in sql with the rendered text and params. The params of a
+// fragment wrapped by Secret are wrapped by Secret too.
+func resolveFragments(dialect Dialect, sql string, params []any) (string, []any, error) {
+	found := false
+	for _, p := range params {
+		if _, _, ok := asFragment(p); ok {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return sql, params, nil
+	}
+
+	parts := strings.Split(sql, paramPh)
+	var builder strings.Builder
+	var newParams []any
+	for i, p := range params {
+		builder.WriteString(parts[i])
+
+		f, secret, ok := asFragment(p)
+		if !ok {
+			builder.WriteString(paramPh)
+			newParams = append(newParams, p)
+			continue
+		}
+
+		fq, err := f.render(dialect)
+		if err != nil {
+			return "", nil, err
+		}
+		fsql, fparams, err := fq.toSql()
+		if err == nil {
+			fsql, fparams, err = resolveFragments(dialect, fsql, fparams)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if secret {
+			fparams = secretParams(fparams)
+		}
+		builder.WriteString(fsql)
+		newParams = append(newParams, fparams...)
+	}
+	builder.WriteString(parts[len(parts)-1])
+
+	return builder.String(), newParams, nil
+}
+
+// asFragment returns p as a fragment, unwrapping a fragment wrapped by
+// Secret, and whether it was wrapped.
+func asFragment(p any) (fragment, bool, bool) {
+	if r, ok := p.(Redacted); ok {
+		f, ok := r.value.(fragment)
+		return f, true, ok
+	}
+	f, ok := p.(fragment)
+	return f, false, ok
+}
+
 func convertArg(text string, arg any) (string, []any, []error) {
 	var newArgs []any
 	var errs []error
 
 	switch v := arg.(type) {
 
+	case Redacted:
+		argText, args, argErrs := convertArg(text, v.value)
+		for _, a := range args {
+			newArgs = append(newArgs, Secret(a))
+		}
+		return argText, newArgs, argErrs
+
+	case fragment:
+		text = strings.Replace(text, "?", paramPh, 1)
+		newArgs = append(newArgs, v)
+
 	case Embedder:
 		text = strings.Replace(text, "?", v.RawValue(), 1)
 
@@ -115,6 +197,11 @@ func convertArg(text string, arg any) (string, []any, []error) {
 			newArgs = append(newArgs, nil)
 			return text, newArgs, errs
 		}
+		if v.guarded {
+			text = strings.Replace(text, "?", paramPh, 1)
+			newArgs = append(newArgs, guard{query: v})
+			return text, newArgs, errs
+		}
 		sql, params, err := v.toSql()
 		text = strings.Replace(text, "?", sql, 1)
 		if err != nil {
@@ -125,7 +212,7 @@ func convertArg(text string, arg any) (string, []any, []error) {
 
 	case JsonMap, JsonList:
 		text = strings.Replace(text, "?", paramPh, 1)
-		bytes, err := json.Marshal(v)
+		bytes, err := jsonMarshal(v)
 		if err != nil {
 			errs = append(errs, fmt.Errorf("cann jsonify struct: %v", err))
 		} else {
@@ -134,7 +221,16 @@ func convertArg(text string, arg any) (string, []any, []error) {
 
 	case *JsonMap, *JsonList:
 		text = strings.Replace(text, "?", paramPh, 1)
-		bytes, err := json.Marshal(v)
+		bytes, err := jsonMarshal(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cann jsonify struct: %v", err))
+		} else {
+			newArgs = append(newArgs, string(bytes))
+		}
+
+	case jsonValuer:
+		text = strings.Replace(text, "?", paramPh, 1)
+		bytes, err := jsonMarshal(v.jsonValue())
 		if err != nil {
 			errs = append(errs, fmt.Errorf("cann jsonify struct: %v", err))
 		} else {
@@ -175,9 +271,11 @@ func makePart(text string, args ...any) QueryPart {
 	text = strings.ReplaceAll(text, "??", tempPh)
 
 	var newArgs []any
+	var subqueries []subquery
 	errs := make([]error, 0)
 
 	for _, arg := range args {
+		subqueries = append(subqueries, subqueriesOf(arg)...)
 		argText, fArgs, argErrs := convertArg(text, arg)
 		if len(argErrs) > 0 {
 			errs = append(errs, argErrs...)
@@ -193,9 +291,10 @@ func makePart(text string, args ...any) QueryPart {
 	text = strings.ReplaceAll(text, tempPh, "??")
 
 	return QueryPart{
-		Text:   text,
-		Params: newArgs,
-		Errs:   errs,
+		Text:       text,
+		Params:     newArgs,
+		Errs:       errs,
+		subqueries: subqueries,
 	}
 }
 
@@ -212,15 +311,114 @@ func paramToRaw(param any) (string, error) {
 		}
 		return fmt.Sprintf("%v", *p), nil
 	case string:
-		return fmt.Sprintf("'%v'", p), nil
+		return quoteRaw(p), nil
 	case *string:
 		if p == nil {
 			return "NULL", nil
 		}
-		return fmt.Sprintf("'%v'", *p), nil
+		return quoteRaw(*p), nil
 	case nil:
 		return "NULL", nil
+	case Redacted:
+		return redactedText, nil
 	default:
 		return "", fmt.Errorf("unsupported type for Raw query: %T", p)
 	}
 }
+
+func pgArrayElem(v any) (string, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return "NULL", nil
+	}
+
+	switch e := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return pgArrayQuote(e), nil
+	case []byte:
+		return pgArrayQuote(`\x` + hex.EncodeToString(e)), nil
+	case bool:
+		if e {
+			return "t", nil
+		}
+		return "f", nil
+	case float32, float64, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%v", e), nil
+	case time.Time:
+		return pgArrayQuote(e.Format(time.RFC3339Nano)), nil
+	case driver.Valuer:
+		val, err := e.Value()
+		if err != nil {
+			return "", err
+		}
+		return pgArrayElem(val)
+	}
+
+	if rv.Kind() == reflect.Pointer {
+		return pgArrayElem(rv.Elem().Interface())
+	}
+	return pgArrayQuote(fmt.Sprint(v)), nil
+}
+
+func pgArrayQuote(s string) string {
+	if s != "" && !strings.EqualFold(s, "NULL") && !strings.ContainsAny(s, "{},\"\\ \t\r\n") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+func quoteRaw(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// derefPtr returns the value ptr points to, or false if ptr is nil.
+// Values that are not pointers, and pointers which are a *Query, an
+// Embedder or a driver.Valuer, are returned unchanged.
+func derefPtr(ptr any) (any, bool) {
+	if ptr == nil {
+		return nil, false
+	}
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Pointer {
+		return ptr, true
+	}
+	if rv.IsNil() {
+		return nil, false
+	}
+	switch ptr.(type) {
+	case *Query, Embedder, driver.Valuer:
+		return ptr, true
+	}
+	return rv.Elem().Interface(), true
+}
+
+// outermost copies the hooks, comments and policies of inner to outer, which
+// wraps inner as a subquery, since only the outermost Query applies them.
+func outermost(outer, inner *Query) *Query {
+	outer.hooks = inner.hooks
+	outer.comments = inner.comments
+	outer.policies = inner.policies
+	return outer
+}
+
+// allEmpty reports whether args are all empty queries, other than guarded
+// ones which are checked when compiled.
+func allEmpty(args []any) bool {
+	for _, arg := range args {
+		sub, ok := arg.(*Query)
+		if !ok || sub == nil || !sub.Empty() || sub.guarded {
+			return false
+		}
+	}
+	return len(args) > 0
+}
+
+// errQuery returns a Query that fails to compile with err.
+func errQuery(err error) *Query {
+	return &Query{Parts: []QueryPart{{Errs: []error{err}}}}
+}